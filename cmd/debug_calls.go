@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/andrewhowdencom/ruf/internal/model"
+	"github.com/andrewhowdencom/ruf/internal/sourcer"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,15 +15,44 @@ var debugCallsCmd = &cobra.Command{
 	Short: "List all scheduled calls from all sources.",
 	Long:  `List all scheduled calls from all sources.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		s := buildSourcer()
+		s, err := buildSourcer()
+		if err != nil {
+			return err
+		}
 
 		urls := viper.GetStringSlice("source.urls")
-		var allCalls []*model.Call
-
+		var files []string
 		for _, url := range urls {
-			source, _, err := s.Source(url)
+			listed, err := sourcer.List(s, url)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error listing %s: %v\n", url, err)
+				continue
+			}
+			files = append(files, listed...)
+		}
+
+		sources := make(map[string]*sourcer.Source)
+		includes := make(map[string][]string)
+		for _, file := range files {
+			source, _, err := s.Source(file)
 			if err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "Error sourcing from %s: %v\n", url, err)
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error sourcing from %s: %v\n", file, err)
+				continue
+			}
+			sources[file] = source
+			includes[file] = source.Included
+		}
+
+		var allCalls []*model.Call
+		for _, file := range files {
+			source, ok := sources[file]
+			if !ok {
+				continue
+			}
+			// The worker only uses files that another source includes
+			// through that source.
+			if parent, ok := sourcer.IncludedBy(file, includes); ok {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Skipping %s, as it is included by %s\n", file, parent)
 				continue
 			}
 			for i := range source.Calls {
@@ -104,3 +104,59 @@ calls:
 	// Assert that stderr contains an error message for the non-existent file.
 	assert.Contains(t, stderr.String(), "Error sourcing from file://"+nonExistentFile)
 }
+
+func TestDebugCallsCmd_Defaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "defaults.yaml")
+	err := os.WriteFile(file, []byte(`
+defaults:
+  author: "releases@example.com"
+  destinations:
+    - type: slack
+      to: ["#releases"]
+  triggers:
+    - cron: "0 9 * * 1"
+calls:
+  - id: call-1
+    content: "Hello, world!"
+    destinations:
+      - type: slack
+        to: ["#general"]
+`), 0644)
+	assert.NoError(t, err)
+
+	viper.Set("source.urls", []string{"file://" + file})
+
+	// The effective call is shown, with the defaults merged into it.
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"debug", "calls"})
+	err = rootCmd.Execute()
+	assert.NoError(t, err)
+	assert.Empty(t, stderr.String())
+
+	assert.JSONEq(t, `[
+		{
+			"id": "call-1",
+			"author": "releases@example.com",
+			"destinations": [
+				{
+					"type": "slack",
+					"to": ["#general", "#releases"]
+				}
+			],
+			"content": "Hello, world!",
+			"triggers": [
+				{
+					"cron": "0 9 * * 1",
+					"scheduled_at": "0001-01-01T00:00:00Z"
+				}
+			],
+			"campaign": {
+				"id": "defaults",
+				"name": "`+file+`"
+			}
+		}
+	]`, stdout.String())
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/andrewhowdencom/ruf/internal/model"
+	"github.com/andrewhowdencom/ruf/internal/sourcer"
 	"github.com/andrewhowdencom/ruf/internal/templater"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -15,19 +16,29 @@ var debugRenderCmd = &cobra.Command{
 	Long:  `Render a specific call.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		s := buildSourcer()
+		s, err := buildSourcer()
+		if err != nil {
+			return err
+		}
 
 		urls := viper.GetStringSlice("source.urls")
 		var allCalls []*model.Call
 
 		for _, url := range urls {
-			source, _, err := s.Source(url)
+			files, err := sourcer.List(s, url)
 			if err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "Error sourcing from %s: %v\n", url, err)
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error listing %s: %v\n", url, err)
 				continue
 			}
-			for i := range source.Calls {
-				allCalls = append(allCalls, &source.Calls[i])
+			for _, file := range files {
+				source, _, err := s.Source(file)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Error sourcing from %s: %v\n", file, err)
+					continue
+				}
+				for i := range source.Calls {
+					allCalls = append(allCalls, &source.Calls[i])
+				}
 			}
 		}
 
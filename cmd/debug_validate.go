@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -14,7 +15,7 @@ import (
 var debugValidateCmd = &cobra.Command{
 	Use:   "validate [uri]",
 	Short: "Validate a calls file.",
-	Long:  `Validate a calls file.`,
+	Long:  `Validate a calls file, or every calls file in a directory or glob.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		uri := args[0]
@@ -25,26 +26,53 @@ var debugValidateCmd = &cobra.Command{
 		fetcher.AddFetcher("file", sourcer.NewFileFetcher())
 		// Not including git fetcher for now, as it requires more configuration
 
-		parser := sourcer.NewYAMLParser()
+		parser := sourcer.NewParsers(true)
 		s := sourcer.NewSourcer(fetcher, parser)
 
-		source, _, err := s.Source(uri)
+		files, err := sourcer.List(s, uri)
 		if err != nil {
 			return err
 		}
-
-		// Create a slice of pointers for validation
-		callsToValidate := make([]*model.Call, len(source.Calls))
-		for i := range source.Calls {
-			callsToValidate[i] = &source.Calls[i]
+		if len(files) == 0 {
+			return fmt.Errorf("no source files found in %s", uri)
 		}
 
-		errs := validator.Validate(callsToValidate)
-		if len(errs) > 0 {
-			var errStrings []string
+		var errStrings []string
+		for _, file := range files {
+			source, _, err := s.Source(file)
+			if err != nil {
+				// Every problem in every file is reported, so parse errors
+				// don't stop the other files from being validated.
+				if len(files) == 1 {
+					return err
+				}
+				var diags sourcer.Diagnostics
+				if errors.As(err, &diags) {
+					errStrings = append(errStrings, diags.Error())
+				} else {
+					errStrings = append(errStrings, fmt.Sprintf("%s: %s", file, err))
+				}
+				continue
+			}
+
+			// Create a slice of pointers for validation
+			callsToValidate := make([]*model.Call, len(source.Calls))
+			for i := range source.Calls {
+				callsToValidate[i] = &source.Calls[i]
+			}
+
+			errs := validator.Validate(callsToValidate)
+			errs = append(errs, validator.ValidateEvents(source.Events)...)
 			for _, err := range errs {
+				// Name the file when more than one is validated.
+				if len(files) > 1 {
+					errStrings = append(errStrings, fmt.Sprintf("%s: %s", file, err))
+					continue
+				}
 				errStrings = append(errStrings, err.Error())
 			}
+		}
+		if len(errStrings) > 0 {
 			return fmt.Errorf("validation failed:\n%s", strings.Join(errStrings, "\n"))
 		}
 
@@ -93,6 +93,61 @@ calls:
 		t.Fatal(err)
 	}
 
+	// Test case 6: Invalid event duration
+	invalidEventYAML := `
+calls:
+  - subject: "Test Subject"
+    content: "Test Content"
+    destinations:
+      - type: "email"
+        to: ["all-hands@example.com"]
+    triggers:
+      - sequence: "launch"
+        delta: "-5m"
+events:
+  - sequence: "launch"
+    start_time: "2025-01-01T12:00:00Z"
+    duration: "an hour"
+    invite: true
+`
+	invalidEventFile := filepath.Join(tmpdir, "invalid_event.yaml")
+	if err := ioutil.WriteFile(invalidEventFile, []byte(invalidEventYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Test case 7: Invalid push notification priority
+	invalidPriorityYAML := `
+calls:
+  - subject: "Test Subject"
+    content: "Test Content"
+    destinations:
+      - type: "ntfy"
+        to: ["on-call"]
+        priority: 9
+    triggers:
+      - scheduled_at: "2025-01-01T12:00:00Z"
+`
+	invalidPriorityFile := filepath.Join(tmpdir, "invalid_priority.yaml")
+	if err := ioutil.WriteFile(invalidPriorityFile, []byte(invalidPriorityYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Test case 8: Misspelled field
+	misspelledYAML := `
+calls:
+  - subject: "Test Subject"
+    content: "Test Content"
+    destinations:
+      - type: "slack"
+        to: ["#general"]
+    triggers:
+      - schedule_at: "2025-01-01T12:00:00Z"
+`
+	misspelledFile := filepath.Join(tmpdir, "misspelled.yaml")
+	if err := ioutil.WriteFile(misspelledFile, []byte(misspelledYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	testCases := []struct {
 		name          string
 		args          []string
@@ -129,6 +184,24 @@ calls:
 			expectedOutput: "",
 			expectError:   true,
 		},
+		{
+			name:          "invalid event duration",
+			args:          []string{"validate", "file://" + invalidEventFile},
+			expectedOutput: "",
+			expectError:   true,
+		},
+		{
+			name:          "invalid priority",
+			args:          []string{"validate", "file://" + invalidPriorityFile},
+			expectedOutput: "",
+			expectError:   true,
+		},
+		{
+			name:          "misspelled field",
+			args:          []string{"validate", "file://" + misspelledFile},
+			expectedOutput: misspelledFile + `:9:9: unknown field "schedule_at" in trigger, did you mean "scheduled_at"?`,
+			expectError:   true,
+		},
 		{
 			name:          "file not found",
 			args:          []string{"validate", "file:///nonexistent.yaml"},
@@ -43,12 +43,58 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 	viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
 
+	viper.SetDefault("source.strict", false)
+	viper.SetDefault("source.verify", []any{})
+	viper.SetDefault("source.concurrency", 4)
+	viper.SetDefault("source.timeout", "1m")
+	viper.SetDefault("source.max_backoff", "1h")
 	viper.SetDefault("email.host", "")
 	viper.SetDefault("email.port", 587)
 	viper.SetDefault("email.username", "")
 	viper.SetDefault("email.password", "")
 	viper.SetDefault("email.from", "")
+	viper.SetDefault("email.tls", "opportunistic")
+	viper.SetDefault("email.auth", "")
+	viper.SetDefault("email.ca_file", "")
 	viper.SetDefault("git.tokens", map[string]string{})
+	viper.SetDefault("git.cache_dir", "")
+	viper.SetDefault("http.timeout", "30s")
+	viper.SetDefault("http.retries", 3)
+	viper.SetDefault("s3.endpoint", "")
+	viper.SetDefault("s3.region", "")
+	viper.SetDefault("s3.access_key_id", "")
+	viper.SetDefault("s3.secret_access_key", "")
+	viper.SetDefault("s3.session_token", "")
+	viper.SetDefault("s3.path_style", false)
+	viper.SetDefault("teams.webhooks", map[string]string{})
+	viper.SetDefault("discord.webhooks", map[string]string{})
+	viper.SetDefault("discord.username", "")
+	viper.SetDefault("mattermost.url", "")
+	viper.SetDefault("mattermost.token", "")
+	viper.SetDefault("mattermost.team", "")
+	viper.SetDefault("matrix.homeserver", "")
+	viper.SetDefault("matrix.access_token", "")
+	viper.SetDefault("telegram.token", "")
+	viper.SetDefault("telegram.api_url", "https://api.telegram.org")
+	viper.SetDefault("telegram.parse_mode", "HTML")
+	viper.SetDefault("googlechat.webhooks", map[string]string{})
+	viper.SetDefault("googlechat.format", "card")
+	viper.SetDefault("file.format", "markdown")
+	viper.SetDefault("file.dir", "")
+	viper.SetDefault("ntfy.server", "https://ntfy.sh")
+	viper.SetDefault("ntfy.token", "")
+	viper.SetDefault("gotify.server", "")
+	viper.SetDefault("gotify.apps", map[string]string{})
+	viper.SetDefault("sms.api_url", "https://api.twilio.com")
+	viper.SetDefault("sms.account_sid", "")
+	viper.SetDefault("sms.auth_token", "")
+	viper.SetDefault("sms.from", "")
+	viper.SetDefault("sms.max_segments", 3)
+	viper.SetDefault("feed.dir", "")
+	viper.SetDefault("feed.url", "")
+	viper.SetDefault("feed.listen", "")
+	viper.SetDefault("feed.max_entries", 50)
+	viper.SetDefault("github.api_urls", map[string]string{})
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/andrewhowdencom/ruf/internal/clients"
 	"github.com/andrewhowdencom/ruf/internal/clients/slack"
 	"github.com/andrewhowdencom/ruf/internal/datastore"
 	"github.com/spf13/cobra"
@@ -32,18 +33,33 @@ var sentDeleteCmd = &cobra.Command{
 			return fmt.Errorf("failed to get sent message: %w", err)
 		}
 
-		if sm.Type == "slack" {
+		deleted := false
+		switch sm.Type {
+		case "slack":
 			client := slack.NewClient(viper.GetString("slack.app.token"))
 			if err := client.DeleteMessage(sm.Destination, sm.Timestamp); err != nil {
 				return fmt.Errorf("failed to delete message from slack: %w", err)
 			}
+			deleted = true
+		default:
+			deleter, ok := buildSenders(store)[sm.Type].(clients.Deleter)
+			if ok && sm.Reference != "" {
+				if err := deleter.Delete(sm.Destination, sm.Reference); err != nil {
+					return fmt.Errorf("failed to delete message from %s: %w", sm.Type, err)
+				}
+				deleted = true
+			}
 		}
 
 		if err := store.DeleteSentMessage(callID); err != nil {
 			return fmt.Errorf("failed to delete sent message from datastore: %w", err)
 		}
 
-		fmt.Fprintf(cmd.OutOrStdout(), "Successfully deleted call with ID '%s' from Slack and marked as deleted in the database.\n", callID)
+		if deleted {
+			fmt.Fprintf(cmd.OutOrStdout(), "Successfully deleted call with ID '%s' from %s and marked as deleted in the database.\n", callID, sm.Type)
+		} else {
+			fmt.Fprintf(cmd.OutOrStdout(), "Marked call with ID '%s' as deleted in the database. Messages sent to %s cannot be deleted.\n", callID, sm.Type)
+		}
 
 		return nil
 	},
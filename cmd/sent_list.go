@@ -27,10 +27,15 @@ var sentListCmd = &cobra.Command{
 		}
 
 		table := tablewriter.NewWriter(os.Stdout)
-		table.Header([]string{"ID", "Campaign", "Status", "Source ID", "Scheduled At", "Timestamp"})
+		table.Header([]string{"ID", "Campaign", "Status", "Source ID", "Scheduled At", "Reference"})
 
 		for _, m := range messages {
-			table.Append([]string{m.ID, m.CampaignName, string(m.Status), m.SourceID, m.ScheduledAt.String(), m.Timestamp})
+			// Slack messages are referenced by their timestamp.
+			reference := m.Reference
+			if m.Timestamp != "" {
+				reference = m.Timestamp
+			}
+			table.Append([]string{m.ID, m.CampaignName, string(m.Status), m.SourceID, m.ScheduledAt.String(), reference})
 		}
 
 		table.Render()
@@ -1,12 +1,27 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
 
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/andrewhowdencom/ruf/internal/clients/discord"
 	"github.com/andrewhowdencom/ruf/internal/clients/email"
+	"github.com/andrewhowdencom/ruf/internal/clients/feed"
+	"github.com/andrewhowdencom/ruf/internal/clients/file"
+	"github.com/andrewhowdencom/ruf/internal/clients/github"
+	"github.com/andrewhowdencom/ruf/internal/clients/googlechat"
+	"github.com/andrewhowdencom/ruf/internal/clients/gotify"
+	"github.com/andrewhowdencom/ruf/internal/clients/matrix"
+	"github.com/andrewhowdencom/ruf/internal/clients/mattermost"
+	"github.com/andrewhowdencom/ruf/internal/clients/ntfy"
 	"github.com/andrewhowdencom/ruf/internal/clients/slack"
+	"github.com/andrewhowdencom/ruf/internal/clients/sms"
+	"github.com/andrewhowdencom/ruf/internal/clients/teams"
+	"github.com/andrewhowdencom/ruf/internal/clients/telegram"
 	"github.com/andrewhowdencom/ruf/internal/datastore"
 	"github.com/andrewhowdencom/ruf/internal/poller"
 	"github.com/andrewhowdencom/ruf/internal/sourcer"
@@ -25,14 +40,86 @@ var workerCmd = &cobra.Command{
 	},
 }
 
-func buildSourcer() sourcer.Sourcer {
+func buildSourcer() (sourcer.Sourcer, error) {
 	fetcher := sourcer.NewCompositeFetcher()
 	fetcher.AddFetcher("http", sourcer.NewHTTPFetcher())
 	fetcher.AddFetcher("https", sourcer.NewHTTPFetcher())
 	fetcher.AddFetcher("file", sourcer.NewFileFetcher())
-	fetcher.AddFetcher("git", sourcer.NewGitFetcher())
-	parser := sourcer.NewYAMLParser()
-	return sourcer.NewSourcer(fetcher, parser)
+	git := sourcer.NewGitFetcher()
+	for _, scheme := range []string{"git", "git+https", "git+http", "git+ssh", "git+file"} {
+		fetcher.AddFetcher(scheme, git)
+	}
+	fetcher.AddFetcher("s3", sourcer.NewS3Fetcher(sourcer.S3Config{
+		Endpoint:        viper.GetString("s3.endpoint"),
+		Region:          viper.GetString("s3.region"),
+		AccessKeyID:     viper.GetString("s3.access_key_id"),
+		SecretAccessKey: viper.GetString("s3.secret_access_key"),
+		SessionToken:    viper.GetString("s3.session_token"),
+		PathStyle:       viper.GetBool("s3.path_style"),
+	}))
+
+	var rules []sourcer.VerifyRule
+	if err := viper.UnmarshalKey("source.verify", &rules); err != nil {
+		return nil, fmt.Errorf("failed to read source.verify: %w", err)
+	}
+	verifier, err := sourcer.NewVerifyingFetcher(fetcher, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure source verification: %w", err)
+	}
+
+	parser := sourcer.NewParsers(viper.GetBool("source.strict"))
+	return sourcer.NewSourcer(verifier, parser), nil
+}
+
+// buildSenders returns the senders for every destination type other than
+// slack and email, keyed by destination type.
+func buildSenders(store datastore.Storer) map[string]clients.Sender {
+	return map[string]clients.Sender{
+		"teams":   teams.NewClient(viper.GetStringMapString("teams.webhooks")),
+		"discord": discord.NewClient(viper.GetStringMapString("discord.webhooks"), viper.GetString("discord.username")),
+		"mattermost": mattermost.NewClient(
+			viper.GetString("mattermost.url"),
+			viper.GetString("mattermost.token"),
+			viper.GetString("mattermost.team"),
+		),
+		"matrix": matrix.NewClient(viper.GetString("matrix.homeserver"), viper.GetString("matrix.access_token")),
+		"googlechat": googlechat.NewClient(
+			viper.GetStringMapString("googlechat.webhooks"),
+			viper.GetString("googlechat.format"),
+		),
+		"telegram": telegram.NewClient(
+			viper.GetString("telegram.api_url"),
+			viper.GetString("telegram.token"),
+			viper.GetString("telegram.parse_mode"),
+		),
+		"file": file.NewClient(file.Config{
+			Format: viper.GetString("file.format"),
+			From:   viper.GetString("email.from"),
+			Dir:    viper.GetString("file.dir"),
+		}),
+		"ntfy":   ntfy.NewClient(viper.GetString("ntfy.server"), viper.GetString("ntfy.token")),
+		"gotify": gotify.NewClient(viper.GetString("gotify.server"), viper.GetStringMapString("gotify.apps")),
+		"sms": sms.NewClient(sms.Config{
+			APIURL:      viper.GetString("sms.api_url"),
+			AccountSID:  viper.GetString("sms.account_sid"),
+			AuthToken:   viper.GetString("sms.auth_token"),
+			From:        viper.GetString("sms.from"),
+			MaxSegments: viper.GetInt("sms.max_segments"),
+		}),
+		"feed": feed.NewClient(store, feedConfig()),
+		"github": github.NewClient(viper.GetStringMapString("github.api_urls"), func(host string) string {
+			// The same tokens that the git fetcher uses to clone repositories.
+			return viper.GetString(fmt.Sprintf("git.auth.%s.token", host))
+		}),
+	}
+}
+
+func feedConfig() feed.Config {
+	return feed.Config{
+		Dir:        viper.GetString("feed.dir"),
+		BaseURL:    viper.GetString("feed.url"),
+		MaxEntries: viper.GetInt("feed.max_entries"),
+	}
 }
 
 func runWorker() error {
@@ -46,15 +133,24 @@ func runWorker() error {
 	slackToken := viper.GetString("slack.app.token")
 	slackClient := slack.NewClient(slackToken)
 
-	emailClient := email.NewClient(
-		viper.GetString("email.host"),
-		viper.GetInt("email.port"),
-		viper.GetString("email.username"),
-		viper.GetString("email.password"),
-		viper.GetString("email.from"),
-	)
+	emailClient, err := email.NewClient(email.Config{
+		Host:     viper.GetString("email.host"),
+		Port:     viper.GetInt("email.port"),
+		Username: viper.GetString("email.username"),
+		Password: viper.GetString("email.password"),
+		From:     viper.GetString("email.from"),
+		TLS:      viper.GetString("email.tls"),
+		Auth:     viper.GetString("email.auth"),
+		CAFile:   viper.GetString("email.ca_file"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create email client: %w", err)
+	}
 
-	s := buildSourcer()
+	s, err := buildSourcer()
+	if err != nil {
+		return err
+	}
 	pollInterval := viper.GetDuration("worker.interval")
 	if pollInterval == 0 {
 		pollInterval = 1 * time.Minute
@@ -62,6 +158,25 @@ func runWorker() error {
 	p := poller.New(s, pollInterval)
 
 	w := worker.New(store, slackClient, emailClient, p, pollInterval)
+	for destType, sender := range buildSenders(store) {
+		w.AddSender(destType, sender)
+	}
+
+	if addr := viper.GetString("feed.listen"); addr != "" {
+		server := &http.Server{
+			Addr:              addr,
+			Handler:           feed.NewClient(store, feedConfig()),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		defer server.Close()
+		go func() {
+			slog.Info("serving feeds", "addr", addr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("failed to serve feeds", "error", err)
+			}
+		}()
+	}
+
 	return w.Run()
 }
 
@@ -0,0 +1,118 @@
+package calendar
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Methods of an iTIP message, as used in the METHOD property and the
+// text/calendar content type.
+const (
+	MethodRequest = "REQUEST"
+	MethodCancel  = "CANCEL"
+)
+
+const timeFormat = "20060102T150405Z"
+
+// Invite represents a single calendar event invitation.
+type Invite struct {
+	UID         string
+	Method      string
+	Sequence    int
+	Summary     string
+	Description string
+	Organizer   string
+	Attendees   []string
+	Start       time.Time
+	End         time.Time
+	Stamp       time.Time
+}
+
+// UID returns a stable identifier for an event derived from the given parts.
+func UID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return fmt.Sprintf("%x@ruf", sum[:16])
+}
+
+// Render renders the invite as an iCalendar (RFC 5545) object.
+func (i *Invite) Render() []byte {
+	method := i.Method
+	if method == "" {
+		method = MethodRequest
+	}
+	stamp := i.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//ruf//ruf//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:" + method,
+		"BEGIN:VEVENT",
+		"UID:" + i.UID,
+		fmt.Sprintf("SEQUENCE:%d", i.Sequence),
+		"DTSTAMP:" + stamp.UTC().Format(timeFormat),
+		"DTSTART:" + i.Start.UTC().Format(timeFormat),
+	}
+	if !i.End.IsZero() {
+		lines = append(lines, "DTEND:"+i.End.UTC().Format(timeFormat))
+	}
+	lines = append(lines, "SUMMARY:"+escape(i.Summary))
+	if i.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escape(i.Description))
+	}
+	if i.Organizer != "" {
+		lines = append(lines, "ORGANIZER:mailto:"+i.Organizer)
+	}
+	for _, attendee := range i.Attendees {
+		lines = append(lines, "ATTENDEE;ROLE=REQ-PARTICIPANT;RSVP=FALSE:mailto:"+attendee)
+	}
+	if method == MethodCancel {
+		lines = append(lines, "STATUS:CANCELLED")
+	} else {
+		lines = append(lines, "STATUS:CONFIRMED")
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(fold(line))
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String())
+}
+
+// escape escapes a TEXT property value.
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// fold splits a content line into lines of at most 75 octets, without
+// breaking up multi-byte characters.
+func fold(line string) string {
+	const limit = 75
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
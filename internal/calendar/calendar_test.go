@@ -0,0 +1,60 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInviteRender(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	invite := &Invite{
+		UID:         "abc@ruf",
+		Sequence:    2,
+		Summary:     "Launch; party, with friends",
+		Description: "Line one\nLine two",
+		Organizer:   "ruf@example.com",
+		Attendees:   []string{"all-hands@example.com"},
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Stamp:       start,
+	}
+
+	ics := string(invite.Render())
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "METHOD:REQUEST\r\n")
+	assert.Contains(t, ics, "UID:abc@ruf\r\n")
+	assert.Contains(t, ics, "SEQUENCE:2\r\n")
+	assert.Contains(t, ics, "DTSTART:20250101T120000Z\r\n")
+	assert.Contains(t, ics, "DTEND:20250101T130000Z\r\n")
+	assert.Contains(t, ics, `SUMMARY:Launch\; party\, with friends`+"\r\n")
+	assert.Contains(t, ics, `DESCRIPTION:Line one\nLine two`+"\r\n")
+	assert.Contains(t, ics, "ORGANIZER:mailto:ruf@example.com\r\n")
+	assert.Contains(t, ics, "mailto:all-hands@example.com\r\n")
+	assert.Contains(t, ics, "STATUS:CONFIRMED\r\n")
+
+	invite.Method = MethodCancel
+	invite.End = time.Time{}
+	ics = string(invite.Render())
+	assert.Contains(t, ics, "METHOD:CANCEL\r\n")
+	assert.Contains(t, ics, "STATUS:CANCELLED\r\n")
+	assert.NotContains(t, ics, "DTEND")
+}
+
+func TestFold(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 100)
+	folded := fold(line)
+	for _, l := range strings.Split(folded, "\r\n") {
+		assert.LessOrEqual(t, len(l), 75)
+	}
+	assert.Equal(t, line, strings.ReplaceAll(folded, "\r\n ", ""))
+}
+
+func TestUID(t *testing.T) {
+	assert.Equal(t, UID("campaign", "event"), UID("campaign", "event"))
+	assert.NotEqual(t, UID("campaign", "event"), UID("campaign", "other"))
+	assert.Regexp(t, `^[0-9a-f]{32}@ruf$`, UID("campaign", "event"))
+}
@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a rendered call, ready to be sent to a single address.
+type Message struct {
+	CampaignID string
+	CallID     string
+	// ThreadID is the ID of the call that starts the thread this message
+	// belongs to. It is empty for calls that are not part of a sequence.
+	ThreadID string
+	// ReplyTo is the reference of the message that starts the thread, if it
+	// has already been sent to the same address.
+	ReplyTo string
+	// ScheduledAt is the time the call was scheduled to be sent at.
+	ScheduledAt time.Time
+	// SourceURL is the URL of the source that the call was read from.
+	SourceURL string
+
+	To      string
+	Author  string
+	Subject string
+	Content string
+
+	// Priority, Tags and Click are set for push notification destinations.
+	// Priority is zero if the destination doesn't set one.
+	Priority int
+	Tags     []string
+	Click    string
+}
+
+// Sender is implemented by clients that can send a message to a destination.
+type Sender interface {
+	// Send sends the message and returns a reference to it, such as a message
+	// ID, if the destination provides one.
+	Send(msg *Message) (string, error)
+}
+
+// Deleter is implemented by senders that can delete a message they have sent.
+type Deleter interface {
+	// Delete deletes the message with the given reference, as returned by
+	// Send, from the destination address it was sent to.
+	Delete(to, reference string) error
+}
+
+// Rebuilder is implemented by senders that rebuild what they publish from the
+// records of the messages sent to them, such as feeds. The rendered author,
+// subject and content are only kept in the records of these senders.
+type Rebuilder interface {
+	// RebuildsFromRecords is a marker method, and does nothing.
+	RebuildsFromRecords()
+}
+
+// Webhooks maps aliases, which can be used as destination addresses in place
+// of webhook URLs, to webhook URLs. This keeps secret URLs out of call files.
+type Webhooks map[string]string
+
+// NewWebhooks creates a new Webhooks from a map of aliases to URLs. Aliases
+// are case-insensitive, as configuration keys are.
+func NewWebhooks(aliases map[string]string) Webhooks {
+	w := make(Webhooks, len(aliases))
+	for alias, url := range aliases {
+		w[strings.ToLower(alias)] = url
+	}
+	return w
+}
+
+// Resolve returns the webhook URL for a destination address, which is either
+// a configured alias or a webhook URL.
+func (w Webhooks) Resolve(to string) (string, error) {
+	if strings.HasPrefix(to, "https://") || strings.HasPrefix(to, "http://") {
+		return to, nil
+	}
+	url, ok := w[strings.ToLower(to)]
+	if !ok {
+		return "", fmt.Errorf("webhook '%s' not found", to)
+	}
+	return url, nil
+}
@@ -0,0 +1,22 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooks_Resolve(t *testing.T) {
+	w := NewWebhooks(map[string]string{"Engineering": "https://example.com/hook"})
+
+	url, err := w.Resolve("engineering")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/hook", url)
+
+	url, err = w.Resolve("https://example.com/other")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/other", url)
+
+	_, err = w.Resolve("marketing")
+	assert.ErrorContains(t, err, "webhook 'marketing' not found")
+}
@@ -0,0 +1,179 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// Limits imposed by Discord on webhook messages.
+const (
+	maxUsername    = 80
+	maxTitle       = 256
+	maxDescription = 4096
+)
+
+// Client posts messages to Discord channels through webhooks.
+type Client struct {
+	httpClient *http.Client
+	webhooks   clients.Webhooks
+	username   string
+}
+
+// NewClient creates a new Discord client. Webhooks maps aliases that can be
+// used as destination addresses to webhook URLs. Username is the name that
+// messages are posted as; if it is empty, messages are posted as the local
+// part of the call's author, such as "releases" for "releases@example.com",
+// or as the webhook's own name if the call has no author.
+func NewClient(webhooks map[string]string, username string) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		webhooks:   clients.NewWebhooks(webhooks),
+		username:   username,
+	}
+}
+
+type embed struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type payload struct {
+	Username        string          `json:"username,omitempty"`
+	Embeds          []embed         `json:"embeds"`
+	AllowedMentions allowedMentions `json:"allowed_mentions"`
+}
+
+type allowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+// Send posts the message to the webhook as an embed, and returns the ID of
+// the message that was created.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	webhookURL, err := c.webhooks.Resolve(msg.To)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve discord webhook: %w", err)
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse discord webhook url: %w", err)
+	}
+	// Ask Discord to wait for the message to be created, so that it returns it.
+	q := u.Query()
+	q.Set("wait", "true")
+	u.RawQuery = q.Encode()
+
+	body, err := json.Marshal(payload{
+		Username: truncate(c.usernameFor(msg), maxUsername),
+		Embeds: []embed{
+			{
+				Title:       truncate(msg.Subject, maxTitle),
+				Description: truncate(msg.Content, maxDescription),
+			},
+		},
+		// Don't ping anyone mentioned in the content.
+		AllowedMentions: allowedMentions{Parse: []string{}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	resp, err := c.httpClient.Post(u.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to post to discord webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return "", fmt.Errorf("failed to post to discord webhook: %w", err)
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode discord message: %w", err)
+	}
+
+	return created.ID, nil
+}
+
+// usernameFor returns the name to post a message as, rather than the
+// author's full email address.
+func (c *Client) usernameFor(msg *clients.Message) string {
+	if c.username != "" {
+		return c.username
+	}
+	local, _, _ := strings.Cut(msg.Author, "@")
+	return local
+}
+
+// Delete deletes a message that was sent through the webhook.
+func (c *Client) Delete(to, messageID string) error {
+	u, err := c.messageURL(to, messageID)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete discord message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("failed to delete discord message: %w", err)
+	}
+	return nil
+}
+
+// messageURL returns the URL of a message sent through a webhook, keeping any
+// thread the webhook posts to.
+func (c *Client) messageURL(to, messageID string) (string, error) {
+	webhookURL, err := c.webhooks.Resolve(to)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve discord webhook: %w", err)
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse discord webhook url: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/messages/" + url.PathEscape(messageID)
+	q := u.Query()
+	q.Del("wait")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SendAndDelete(t *testing.T) {
+	var received payload
+	var deleted string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/webhooks/123/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("wait"))
+		assert.Equal(t, "456", r.URL.Query().Get("thread_id"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "1122334455", "channel_id": "789"}`))
+	})
+	mux.HandleFunc("DELETE /api/webhooks/123/token/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "456", r.URL.Query().Get("thread_id"))
+		assert.Empty(t, r.URL.Query().Get("wait"))
+		deleted = r.PathValue("id")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient(map[string]string{"announcements": server.URL + "/api/webhooks/123/token?thread_id=456"}, "")
+
+	id, err := c.Send(&clients.Message{
+		To:      "announcements",
+		Author:  "author@example.com",
+		Subject: "Hello!",
+		Content: "Hello, world!",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1122334455", id)
+
+	assert.Equal(t, "author", received.Username)
+	require.Len(t, received.Embeds, 1)
+	assert.Equal(t, "Hello!", received.Embeds[0].Title)
+	assert.Equal(t, "Hello, world!", received.Embeds[0].Description)
+	assert.Empty(t, received.AllowedMentions.Parse)
+
+	err = c.Delete("announcements", id)
+	require.NoError(t, err)
+	assert.Equal(t, "1122334455", deleted)
+}
+
+func TestClient_SendTruncates(t *testing.T) {
+	var received payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(nil, "")
+	_, err := c.Send(&clients.Message{
+		To:      server.URL,
+		Subject: strings.Repeat("s", 300),
+		Content: strings.Repeat("c", 5000),
+	})
+	require.NoError(t, err)
+	require.Len(t, received.Embeds, 1)
+	assert.Len(t, []rune(received.Embeds[0].Title), maxTitle)
+	assert.Len(t, []rune(received.Embeds[0].Description), maxDescription)
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Unknown Webhook", "code": 10015}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c := NewClient(map[string]string{"gone": server.URL}, "")
+
+	_, err := c.Send(&clients.Message{To: "gone", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 404")
+	assert.ErrorContains(t, err, "Unknown Webhook")
+
+	err = c.Delete("gone", "1")
+	assert.ErrorContains(t, err, "status code 404")
+
+	_, err = c.Send(&clients.Message{To: "unknown", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "webhook 'unknown' not found")
+}
+
+func TestClient_SendUsername(t *testing.T) {
+	var received payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer server.Close()
+
+	// A configured username is used whatever the author.
+	_, err := NewClient(nil, "ruf").Send(&clients.Message{To: server.URL, Author: "author@example.com", Content: "Hello!"})
+	require.NoError(t, err)
+	assert.Equal(t, "ruf", received.Username)
+
+	// Without an author, the webhook's own name is used.
+	received = payload{}
+	_, err = NewClient(nil, "").Send(&clients.Message{To: server.URL, Content: "Hello!"})
+	require.NoError(t, err)
+	assert.Empty(t, received.Username)
+}
@@ -1,72 +1,406 @@
 package email
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"mime/multipart"
+	"net"
 	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TLS modes supported by the SMTP client.
+const (
+	// TLSOpportunistic upgrades the connection with STARTTLS when the server offers it.
+	TLSOpportunistic = "opportunistic"
+	// TLSStartTLS requires the server to offer STARTTLS and fails otherwise.
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects over TLS from the start, as is usual on port 465.
+	TLSImplicit = "implicit"
+	// TLSNone never upgrades the connection.
+	TLSNone = "none"
+)
+
+// Authentication mechanisms supported by the SMTP client.
+const (
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+	AuthNone    = "none"
 )
 
 // Client is an interface for sending emails.
 type Client interface {
-	Send(to []string, author, subject, body string) error
+	Send(msg *Message) error
+	// Close ends any session that is being held open between sends.
+	Close() error
+}
+
+// Message is an email to be sent to one or more recipients.
+type Message struct {
+	To      []string
+	Author  string
+	Subject string
+	Body    string
+
+	// MessageID is used as the Message-ID header, if set.
+	MessageID string
+	// InReplyTo is the Message-ID of the message this one continues. It is
+	// used for both the In-Reply-To and References headers, if set.
+	InReplyTo string
+
+	// Calendar is an optional iCalendar object, attached alongside the body
+	// as a text/calendar part with the given CalendarMethod.
+	Calendar       []byte
+	CalendarMethod string
+}
+
+// MessageID returns a deterministic Message-ID for the given parts, using the
+// domain of the from address. The same parts always produce the same ID, so
+// messages can reference each other without any prior state.
+func MessageID(from string, parts ...string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at != -1 && at < len(from)-1 {
+		domain = strings.TrimSuffix(from[at+1:], ">")
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return fmt.Sprintf("<%x@%s>", sum[:16], domain)
+}
+
+// Config holds the settings used to connect to an SMTP server.
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+
+	// TLS is one of the TLS* modes. It defaults to TLSOpportunistic.
+	TLS string
+	// Auth is one of the Auth* mechanisms. It defaults to AuthPlain when a
+	// username is set, and AuthNone otherwise.
+	Auth string
+	// CAFile is an optional path to a PEM bundle used to verify the server.
+	CAFile string
 }
 
-// SMTPClient is a client for sending emails using SMTP.
+// SMTPClient is a client for sending emails using SMTP. It keeps a single
+// session open across sends until Close is called.
 type SMTPClient struct {
-	addr string
-	auth smtp.Auth
-	from string
+	addr      string
+	host      string
+	from      string
+	tlsMode   string
+	tlsConfig *tls.Config
+	auth      smtp.Auth
+
+	mu   sync.Mutex
+	conn *smtp.Client
 }
 
 // NewClient creates a new SMTP client.
-func NewClient(host string, port int, username, password, from string) Client {
-	auth := smtp.PlainAuth("", username, password, host)
-	addr := fmt.Sprintf("%s:%d", host, port)
+func NewClient(cfg Config) (Client, error) {
+	tlsMode := cfg.TLS
+	if tlsMode == "" {
+		tlsMode = TLSOpportunistic
+	}
+	switch tlsMode {
+	case TLSOpportunistic, TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("unsupported tls mode: %s", tlsMode)
+	}
 
-	return &SMTPClient{
-		addr: addr,
-		auth: auth,
-		from: from,
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	mechanism := cfg.Auth
+	if mechanism == "" {
+		mechanism = AuthNone
+		if cfg.Username != "" {
+			mechanism = AuthPlain
+		}
+	}
+
+	var auth smtp.Auth
+	switch mechanism {
+	case AuthPlain:
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	case AuthLogin:
+		auth = &loginAuth{username: cfg.Username, password: cfg.Password, host: cfg.Host}
+	case AuthCRAMMD5:
+		auth = smtp.CRAMMD5Auth(cfg.Username, cfg.Password)
+	case AuthNone:
+	default:
+		return nil, fmt.Errorf("unsupported auth mechanism: %s", mechanism)
 	}
+
+	return &SMTPClient{
+		addr:      net.JoinHostPort(cfg.Host, fmt.Sprint(cfg.Port)),
+		host:      cfg.Host,
+		from:      cfg.From,
+		tlsMode:   tlsMode,
+		tlsConfig: tlsConfig,
+		auth:      auth,
+	}, nil
 }
 
-// Send sends an email to the specified recipients.
-func (c *SMTPClient) Send(to []string, author, subject, body string) error {
+// Send sends an email to each of the message's recipients.
+func (c *SMTPClient) Send(msg *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	var errs []error
-	for _, recipient := range to {
-		headers := map[string]string{
-			"To":      recipient,
-			"Subject": subject,
+	for _, recipient := range msg.To {
+		if err := c.send(recipient, c.render(recipient, msg)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send email to %s: %w", recipient, err))
 		}
+	}
 
-		from := c.from
-		if author != "" {
-			from = author
-			headers["Reply-To"] = author
-		}
-		headers["From"] = from
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send email to some recipients: %v", errs)
+	}
+
+	return nil
+}
+
+// render builds the headers and body of the message for a single recipient.
+func (c *SMTPClient) render(recipient string, msg *Message) []byte {
+	from := c.from
+	var headers [][2]string
+	if msg.Author != "" {
+		from = msg.Author
+		headers = append(headers, [2]string{"Reply-To", msg.Author})
+	}
+	headers = append(headers,
+		[2]string{"From", from},
+		[2]string{"To", recipient},
+		[2]string{"Subject", msg.Subject},
+	)
+	if msg.MessageID != "" {
+		headers = append(headers, [2]string{"Message-ID", msg.MessageID})
+	}
+	if msg.InReplyTo != "" {
+		headers = append(headers,
+			[2]string{"In-Reply-To", msg.InReplyTo},
+			[2]string{"References", msg.InReplyTo},
+		)
+	}
+
+	if msg.Calendar == nil {
+		var b bytes.Buffer
+		writeHeaders(&b, headers)
+		b.WriteString(msg.Body)
+		return b.Bytes()
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	part, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=UTF-8"},
+	})
+	part.Write([]byte(msg.Body))
+
+	method := msg.CalendarMethod
+	if method == "" {
+		method = "REQUEST"
+	}
+	part, _ = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {fmt.Sprintf("text/calendar; charset=UTF-8; method=%s", method)},
+		"Content-Disposition": {`attachment; filename="invite.ics"`},
+	})
+	part.Write(msg.Calendar)
+	mw.Close()
+
+	headers = append(headers,
+		[2]string{"MIME-Version", "1.0"},
+		[2]string{"Content-Type", "multipart/mixed; boundary=" + mw.Boundary()},
+	)
+
+	var b bytes.Buffer
+	writeHeaders(&b, headers)
+	b.Write(body.Bytes())
+	return b.Bytes()
+}
 
-		msg := ""
-		for k, v := range headers {
-			msg += fmt.Sprintf("%s: %s\r\n", k, v)
+func writeHeaders(b *bytes.Buffer, headers [][2]string) {
+	for _, h := range headers {
+		fmt.Fprintf(b, "%s: %s\r\n", h[0], h[1])
+	}
+	b.WriteString("\r\n")
+}
+
+// Close quits the open SMTP session, if there is one.
+func (c *SMTPClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Quit()
+	c.conn = nil
+	return err
+}
+
+func (c *SMTPClient) send(recipient string, msg []byte) error {
+	conn, err := c.session()
+	if err != nil {
+		return err
+	}
+
+	if err := c.transmit(conn, recipient, msg); err != nil {
+		// The session may be in an unknown state; start afresh on the next send.
+		conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (c *SMTPClient) transmit(conn *smtp.Client, recipient string, msg []byte) error {
+	if err := conn.Mail(c.from); err != nil {
+		return err
+	}
+	if err := conn.Rcpt(recipient); err != nil {
+		return err
+	}
+	w, err := conn.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// session returns the open SMTP session, dialling a new one if there is none
+// or the existing one has gone stale.
+func (c *SMTPClient) session() (*smtp.Client, error) {
+	if c.conn != nil {
+		if err := c.conn.Reset(); err == nil {
+			return c.conn, nil
 		}
-		msg += "\r\n" + body
+		c.conn.Close()
+		c.conn = nil
+	}
 
-		err := smtp.SendMail(c.addr, c.auth, c.from, []string{recipient}, []byte(msg))
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *SMTPClient) dial() (*smtp.Client, error) {
+	var conn *smtp.Client
+	if c.tlsMode == TLSImplicit {
+		tlsConn, err := tls.Dial("tcp", c.addr, c.tlsConfig)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to send email to %s: %w", recipient, err))
+			return nil, fmt.Errorf("failed to connect to %s: %w", c.addr, err)
+		}
+		conn, err = smtp.NewClient(tlsConn, c.host)
+		if err != nil {
+			tlsConn.Close()
+			return nil, fmt.Errorf("failed to start session with %s: %w", c.addr, err)
+		}
+	} else {
+		var err error
+		conn, err = smtp.Dial(c.addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", c.addr, err)
 		}
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to send email to some recipients: %v", errs)
+	if err := c.handshake(conn); err != nil {
+		conn.Close()
+		return nil, err
 	}
+	return conn, nil
+}
 
+func (c *SMTPClient) handshake(conn *smtp.Client) error {
+	if c.tlsMode == TLSOpportunistic || c.tlsMode == TLSStartTLS {
+		if ok, _ := conn.Extension("STARTTLS"); ok {
+			if err := conn.StartTLS(c.tlsConfig); err != nil {
+				return fmt.Errorf("failed to start tls: %w", err)
+			}
+		} else if c.tlsMode == TLSStartTLS {
+			return errors.New("server does not support STARTTLS")
+		}
+	}
+
+	if c.auth != nil {
+		if ok, _ := conn.Extension("AUTH"); !ok {
+			return errors.New("server does not support AUTH")
+		}
+		if err := conn.Auth(c.auth); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
 	return nil
 }
 
+// loginAuth implements the non-standard but widely deployed LOGIN mechanism.
+type loginAuth struct {
+	username, password, host string
+}
+
+// Start begins the LOGIN exchange. Like smtp.PlainAuth, it refuses to send
+// credentials over an unencrypted connection to anything but localhost.
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next answers the server's username and password prompts.
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:", "User Name\x00":
+		return []byte(a.username), nil
+	case "Password:", "Password\x00":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge: %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
 // MockClient is a mock implementation of the Client interface.
 type MockClient struct {
-	SendFunc func(to []string, author, subject, body string) error
+	SendFunc func(msg *Message) error
 }
 
 // NewMockClient returns a new mock client.
@@ -75,9 +409,14 @@ func NewMockClient() *MockClient {
 }
 
 // Send is the mock implementation of the Send method.
-func (m *MockClient) Send(to []string, author, subject, body string) error {
+func (m *MockClient) Send(msg *Message) error {
 	if m.SendFunc != nil {
-		return m.SendFunc(to, author, subject, body)
+		return m.SendFunc(msg)
 	}
 	return nil
 }
+
+// Close is a no-op for the mock client.
+func (m *MockClient) Close() error {
+	return nil
+}
@@ -0,0 +1,430 @@
+package email
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMessage is a message received by the fakeSMTPServer.
+type fakeMessage struct {
+	From string
+	To   string
+	Data string
+}
+
+// fakeSMTPServer is a minimal in-process SMTP server used to exercise the client.
+type fakeSMTPServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	implicit  bool
+	startTLS  bool
+	auth      bool
+	username  string
+	password  string
+
+	mu          sync.Mutex
+	connections int
+	mechanisms  []string
+	quits       int
+	messages    []fakeMessage
+}
+
+func newFakeSMTPServer(t *testing.T, cert tls.Certificate, implicit, startTLS, auth bool) *fakeSMTPServer {
+	t.Helper()
+
+	s := &fakeSMTPServer{
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		implicit:  implicit,
+		startTLS:  startTLS,
+		auth:      auth,
+		username:  "user",
+		password:  "secret",
+	}
+
+	var err error
+	if implicit {
+		s.ln, err = tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	} else {
+		s.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { s.ln.Close() })
+
+	go func() {
+		for {
+			conn, err := s.ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.connections++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	_, secure := conn.(*tls.Conn)
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 127.0.0.1 ESMTP fake")
+
+	var from string
+	var to string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"127.0.0.1"}
+			if s.startTLS && !secure {
+				lines = append(lines, "STARTTLS")
+			}
+			if s.auth {
+				lines = append(lines, "AUTH PLAIN LOGIN CRAM-MD5")
+			}
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			secure = true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			if s.authenticate(tp, strings.ToUpper(mechanism), initial) {
+				s.mu.Lock()
+				s.mechanisms = append(s.mechanisms, strings.ToUpper(mechanism))
+				s.mu.Unlock()
+				tp.PrintfLine("235 authenticated")
+			} else {
+				tp.PrintfLine("535 authentication failed")
+			}
+		case "MAIL":
+			from = strings.Trim(strings.TrimPrefix(strings.Fields(arg)[0], "FROM:"), "<>")
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			to = strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, fakeMessage{From: from, To: to, Data: string(data)})
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			s.mu.Lock()
+			s.quits++
+			s.mu.Unlock()
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) authenticate(tp *textproto.Conn, mechanism, initial string) bool {
+	prompt := func(challenge string) string {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+		line, _ := tp.ReadLine()
+		decoded, _ := base64.StdEncoding.DecodeString(line)
+		return string(decoded)
+	}
+
+	switch mechanism {
+	case "PLAIN":
+		decoded, _ := base64.StdEncoding.DecodeString(initial)
+		parts := strings.Split(string(decoded), "\x00")
+		return len(parts) == 3 && parts[1] == s.username && parts[2] == s.password
+	case "LOGIN":
+		username := prompt("Username:")
+		password := prompt("Password:")
+		return username == s.username && password == s.password
+	case "CRAM-MD5":
+		challenge := "<1234@127.0.0.1>"
+		username, digest, _ := strings.Cut(prompt(challenge), " ")
+		mac := hmac.New(md5.New, []byte(s.password))
+		mac.Write([]byte(challenge))
+		return username == s.username && digest == hex.EncodeToString(mac.Sum(nil))
+	}
+	return false
+}
+
+// newTestCertificate creates a self-signed certificate for 127.0.0.1 and
+// writes it to a PEM file that can be used as a CA bundle.
+func newTestCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+func TestSMTPClient(t *testing.T) {
+	cert, caFile := newTestCertificate(t)
+
+	testCases := []struct {
+		name          string
+		implicit      bool
+		startTLS      bool
+		auth          bool
+		cfg           Config
+		wantMechanism string
+	}{
+		{
+			name:          "starttls with plain auth",
+			startTLS:      true,
+			auth:          true,
+			cfg:           Config{TLS: TLSStartTLS, Username: "user", Password: "secret", CAFile: caFile},
+			wantMechanism: "PLAIN",
+		},
+		{
+			name:          "implicit tls with login auth",
+			implicit:      true,
+			auth:          true,
+			cfg:           Config{TLS: TLSImplicit, Auth: AuthLogin, Username: "user", Password: "secret", CAFile: caFile},
+			wantMechanism: "LOGIN",
+		},
+		{
+			name:          "opportunistic tls with cram-md5 auth",
+			startTLS:      true,
+			auth:          true,
+			cfg:           Config{Auth: AuthCRAMMD5, Username: "user", Password: "secret", CAFile: caFile},
+			wantMechanism: "CRAM-MD5",
+		},
+		{
+			name: "unauthenticated relay without tls",
+			cfg:  Config{TLS: TLSNone},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, cert, tc.implicit, tc.startTLS, tc.auth)
+
+			cfg := tc.cfg
+			cfg.Host = "127.0.0.1"
+			cfg.Port = server.port()
+			cfg.From = "ruf@example.com"
+			client, err := NewClient(cfg)
+			require.NoError(t, err)
+
+			err = client.Send(&Message{To: []string{"a@example.com", "b@example.com"}, Subject: "Subject", Body: "Hello"})
+			assert.NoError(t, err)
+			err = client.Send(&Message{To: []string{"c@example.com"}, Author: "author@example.com", Subject: "Subject", Body: "Hello again"})
+			assert.NoError(t, err)
+			assert.NoError(t, client.Close())
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+
+			// All recipients share a single session.
+			assert.Equal(t, 1, server.connections)
+			assert.Equal(t, 1, server.quits)
+			if tc.wantMechanism != "" {
+				assert.Equal(t, []string{tc.wantMechanism}, server.mechanisms)
+			} else {
+				assert.Empty(t, server.mechanisms)
+			}
+
+			require.Len(t, server.messages, 3)
+			assert.Equal(t, "a@example.com", server.messages[0].To)
+			assert.Equal(t, "b@example.com", server.messages[1].To)
+			assert.Equal(t, "c@example.com", server.messages[2].To)
+			assert.Equal(t, "ruf@example.com", server.messages[2].From)
+			assert.Contains(t, server.messages[2].Data, "Reply-To: author@example.com")
+			assert.Contains(t, server.messages[2].Data, "Hello again")
+		})
+	}
+}
+
+func TestSMTPClient_RequiredStartTLS(t *testing.T) {
+	cert, _ := newTestCertificate(t)
+	server := newFakeSMTPServer(t, cert, false, false, false)
+
+	client, err := NewClient(Config{Host: "127.0.0.1", Port: server.port(), From: "ruf@example.com", TLS: TLSStartTLS})
+	require.NoError(t, err)
+
+	err = client.Send(&Message{To: []string{"a@example.com"}, Subject: "Subject", Body: "Hello"})
+	assert.ErrorContains(t, err, "server does not support STARTTLS")
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Empty(t, server.messages)
+}
+
+func TestSMTPClient_Reconnects(t *testing.T) {
+	cert, _ := newTestCertificate(t)
+	server := newFakeSMTPServer(t, cert, false, false, false)
+
+	client, err := NewClient(Config{Host: "127.0.0.1", Port: server.port(), From: "ruf@example.com", TLS: TLSNone})
+	require.NoError(t, err)
+
+	assert.NoError(t, client.Send(&Message{To: []string{"a@example.com"}, Subject: "Subject", Body: "Hello"}))
+
+	// Drop the session server-side; the next send should dial a fresh one.
+	client.(*SMTPClient).conn.Text.Close()
+
+	assert.NoError(t, client.Send(&Message{To: []string{"b@example.com"}, Subject: "Subject", Body: "Hello"}))
+	assert.NoError(t, client.Close())
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 2, server.connections)
+	assert.Len(t, server.messages, 2)
+}
+
+func TestSMTPClient_ThreadingHeaders(t *testing.T) {
+	cert, _ := newTestCertificate(t)
+	server := newFakeSMTPServer(t, cert, false, false, false)
+
+	client, err := NewClient(Config{Host: "127.0.0.1", Port: server.port(), From: "ruf@example.com", TLS: TLSNone})
+	require.NoError(t, err)
+
+	root := MessageID("ruf@example.com", "campaign", "call-1")
+	reply := MessageID("ruf@example.com", "campaign", "call-2")
+	assert.NoError(t, client.Send(&Message{To: []string{"a@example.com"}, Subject: "First", Body: "Hello", MessageID: root}))
+	assert.NoError(t, client.Send(&Message{To: []string{"a@example.com"}, Subject: "Second", Body: "Hello", MessageID: reply, InReplyTo: root}))
+	assert.NoError(t, client.Close())
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	require.Len(t, server.messages, 2)
+	assert.Contains(t, server.messages[0].Data, "Message-ID: "+root+"\n")
+	assert.NotContains(t, server.messages[0].Data, "In-Reply-To")
+	assert.Contains(t, server.messages[1].Data, "Message-ID: "+reply+"\n")
+	assert.Contains(t, server.messages[1].Data, "In-Reply-To: "+root+"\n")
+	assert.Contains(t, server.messages[1].Data, "References: "+root+"\n")
+}
+
+func TestSMTPClient_CalendarAttachment(t *testing.T) {
+	cert, _ := newTestCertificate(t)
+	server := newFakeSMTPServer(t, cert, false, false, false)
+
+	client, err := NewClient(Config{Host: "127.0.0.1", Port: server.port(), From: "ruf@example.com", TLS: TLSNone})
+	require.NoError(t, err)
+
+	ics := "BEGIN:VCALENDAR\r\nMETHOD:CANCEL\r\nEND:VCALENDAR\r\n"
+	err = client.Send(&Message{
+		To:             []string{"a@example.com"},
+		Subject:        "Cancelled",
+		Body:           "The event has been cancelled.",
+		Calendar:       []byte(ics),
+		CalendarMethod: "CANCEL",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	require.Len(t, server.messages, 1)
+
+	msg, err := mail.ReadMessage(strings.NewReader(server.messages[0].Data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=UTF-8", part.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(part)
+	assert.Equal(t, "The event has been cancelled.", string(body))
+
+	part, err = mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "text/calendar; charset=UTF-8; method=CANCEL", part.Header.Get("Content-Type"))
+	body, _ = io.ReadAll(part)
+	assert.Equal(t, strings.ReplaceAll(ics, "\r\n", "\n"), strings.ReplaceAll(string(body), "\r\n", "\n"))
+}
+
+func TestMessageID(t *testing.T) {
+	id := MessageID("Ruf <ruf@example.com>", "campaign", "call-1")
+	assert.Regexp(t, `^<[0-9a-f]{32}@example\.com>$`, id)
+	assert.Equal(t, id, MessageID("Ruf <ruf@example.com>", "campaign", "call-1"))
+	assert.NotEqual(t, id, MessageID("Ruf <ruf@example.com>", "campaign", "call-2"))
+	assert.Regexp(t, `@localhost>$`, MessageID("", "campaign", "call-1"))
+}
+
+func TestNewClient_InvalidOptions(t *testing.T) {
+	_, err := NewClient(Config{TLS: "sometimes"})
+	assert.ErrorContains(t, err, "unsupported tls mode")
+
+	_, err = NewClient(Config{Auth: "kerberos"})
+	assert.ErrorContains(t, err, "unsupported auth mechanism")
+
+	_, err = NewClient(Config{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read ca file")
+}
@@ -0,0 +1,327 @@
+package feed
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/andrewhowdencom/ruf/internal/datastore"
+)
+
+// DefaultMaxEntries is the default number of entries in a feed.
+const DefaultMaxEntries = 50
+
+// destType is the destination type that feed entries are recorded as.
+const destType = "feed"
+
+// validName matches feed names, which are used as file names and URL paths.
+var validName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Config is the configuration of a feed client.
+type Config struct {
+	// Dir is the directory that feeds are written to, as "<name>.xml". Feeds
+	// are not written to files if it is empty.
+	Dir string
+	// BaseURL is the URL that feeds are served at, used for the links in
+	// each feed.
+	BaseURL string
+	// MaxEntries is the number of most recent entries in each feed. It
+	// defaults to DefaultMaxEntries.
+	MaxEntries int
+}
+
+// Client publishes calls as Atom feeds. Feeds are built from the records of
+// the calls sent to them in the datastore, so they can be written to files
+// after each call, and served over HTTP.
+type Client struct {
+	store datastore.Storer
+	cfg   Config
+	now   func() time.Time
+
+	// mu serializes writes to feed files.
+	mu sync.Mutex
+}
+
+// NewClient creates a new feed client.
+func NewClient(store datastore.Storer, cfg Config) *Client {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultMaxEntries
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	return &Client{
+		store: store,
+		cfg:   cfg,
+		now:   time.Now,
+	}
+}
+
+// EntryID returns the ID of the feed entry for an occurrence of a call. It is
+// the same for every feed the occurrence is published to, and doesn't change
+// when the feed is rebuilt.
+func EntryID(campaignID, callID string, scheduledAt time.Time) string {
+	return uuid(campaignID, callID, scheduledAt.UTC().Format(time.RFC3339))
+}
+
+// Send publishes the message to the feed named in msg.To, and returns the ID
+// of the entry.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	if !validName.MatchString(msg.To) {
+		return "", fmt.Errorf("invalid feed name '%s': must only contain letters, digits, '.', '_' and '-'", msg.To)
+	}
+
+	id := EntryID(msg.CampaignID, msg.CallID, msg.ScheduledAt)
+	if c.cfg.Dir == "" {
+		return id, nil
+	}
+
+	// The message is only recorded in the datastore after it is sent, so it
+	// is added to the entries read from the datastore.
+	pending := entry{
+		ID:      id,
+		Author:  msg.Author,
+		Subject: msg.Subject,
+		Content: msg.Content,
+		Updated: msg.ScheduledAt,
+	}
+	if err := c.write(msg.To, pending, ""); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Delete removes an entry from a feed.
+func (c *Client) Delete(to, id string) error {
+	if c.cfg.Dir == "" {
+		return nil
+	}
+	return c.write(to, entry{}, id)
+}
+
+// RebuildsFromRecords marks the client as rebuilding feeds from the records of
+// the calls sent to them, so that the worker keeps the rendered call in them.
+func (c *Client) RebuildsFromRecords() {}
+
+// Render renders the feed with the given name.
+func (c *Client) Render(name string) ([]byte, error) {
+	entries, err := c.entries(name, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.render(name, entries)
+}
+
+// ServeHTTP serves the feeds as "/<name>.xml".
+func (c *Client) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".xml")
+	if !ok || !validName.MatchString(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	feed, err := c.Render(name)
+	if err != nil {
+		http.Error(w, "failed to render feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write(feed)
+}
+
+// entry is a call that has been published to a feed.
+type entry struct {
+	ID      string
+	Author  string
+	Subject string
+	Content string
+	Updated time.Time
+}
+
+// entries returns the most recent entries of a feed, newest first, leaving out
+// the entry with the ID in exclude.
+func (c *Client) entries(name, exclude string, pending ...entry) ([]entry, error) {
+	sent, err := c.store.ListSentMessages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sent messages: %w", err)
+	}
+
+	entries := pending
+	for _, sm := range sent {
+		if sm.Type != destType || sm.Destination != name || sm.Status != datastore.StatusSent {
+			continue
+		}
+		if sm.Reference == "" || sm.Reference == exclude {
+			continue
+		}
+		entries = append(entries, entry{
+			ID:      sm.Reference,
+			Author:  sm.Author,
+			Subject: sm.Subject,
+			Content: sm.Content,
+			Updated: sm.ScheduledAt,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Updated.Equal(entries[j].Updated) {
+			return entries[i].Updated.After(entries[j].Updated)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	if len(entries) > c.cfg.MaxEntries {
+		entries = entries[:c.cfg.MaxEntries]
+	}
+	return entries, nil
+}
+
+// write rebuilds the file of a feed, with an optional pending entry that has
+// not been recorded yet, and leaving out the entry with the ID in exclude.
+func (c *Client) write(name string, pending entry, exclude string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var extra []entry
+	if pending.ID != "" {
+		extra = append(extra, pending)
+	}
+	entries, err := c.entries(name, exclude, extra...)
+	if err != nil {
+		return err
+	}
+	feed, err := c.render(name, entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.cfg.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create feed directory: %w", err)
+	}
+
+	// Write to a temporary file first, so that readers never see a partial feed.
+	tmp, err := os.CreateTemp(c.cfg.Dir, "."+name+"-*.xml")
+	if err != nil {
+		return fmt.Errorf("failed to create feed file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(feed); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write feed file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write feed file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write feed file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.cfg.Dir, name+".xml")); err != nil {
+		return fmt.Errorf("failed to write feed file: %w", err)
+	}
+	return nil
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link,omitempty"`
+	Author    atomPerson  `xml:"author"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Content atomText    `xml:"content"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func (c *Client) render(name string, entries []entry) ([]byte, error) {
+	feed := atomFeed{
+		ID:    uuid("feed", name),
+		Title: name,
+		// The feed was last updated when its newest entry was, or now if it
+		// has no entries.
+		Updated:   c.now().UTC().Format(time.RFC3339),
+		Author:    atomPerson{Name: "ruf"},
+		Generator: "ruf",
+	}
+	if len(entries) > 0 {
+		feed.Updated = entries[0].Updated.UTC().Format(time.RFC3339)
+	}
+	if c.cfg.BaseURL != "" {
+		feed.Links = []atomLink{{Rel: "self", Type: "application/atom+xml", Href: c.cfg.BaseURL + "/" + name + ".xml"}}
+	}
+
+	for _, e := range entries {
+		ae := atomEntry{
+			ID:      e.ID,
+			Title:   e.Subject,
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+			Content: atomText{Type: "text", Body: e.Content},
+		}
+		if e.Author != "" {
+			ae.Author = &atomPerson{Name: e.Author}
+			if strings.Contains(e.Author, "@") {
+				ae.Author.Email = e.Author
+			}
+		}
+		feed.Entries = append(feed.Entries, ae)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return nil, fmt.Errorf("failed to encode feed: %w", err)
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// namespace is the UUID that the IDs of feeds and entries are named in.
+var namespace = [16]byte{0xa7, 0x2a, 0xfa, 0x80, 0xe5, 0x2e, 0x4c, 0x83, 0xae, 0x8c, 0x27, 0xb3, 0x31, 0xeb, 0xbb, 0x5c}
+
+// uuid returns a name based (version 5) UUID URN for the parts, named in
+// namespace as described in RFC 9562.
+func uuid(parts ...string) string {
+	sum := sha1.Sum(append(namespace[:], strings.Join(parts, "\x00")...))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
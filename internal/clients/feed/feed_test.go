@@ -0,0 +1,179 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/andrewhowdencom/ruf/internal/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// send sends a message through the client and records it, as the worker does.
+func send(t *testing.T, c *Client, store datastore.Storer, msg *clients.Message) string {
+	t.Helper()
+
+	id, err := c.Send(msg)
+	require.NoError(t, err)
+	require.NoError(t, store.AddSentMessage(msg.CampaignID, msg.CallID, &datastore.SentMessage{
+		SourceID:    msg.CallID,
+		ScheduledAt: msg.ScheduledAt,
+		Reference:   id,
+		Destination: msg.To,
+		Type:        "feed",
+		Status:      datastore.StatusSent,
+		Author:      msg.Author,
+		Subject:     msg.Subject,
+		Content:     msg.Content,
+	}))
+	return id
+}
+
+func readFeed(t *testing.T, path string) atomFeed {
+	t.Helper()
+
+	buf, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(buf, &feed))
+	return feed
+}
+
+func TestClient_WritesFeed(t *testing.T) {
+	dir := t.TempDir()
+	store := datastore.NewMockStore()
+	c := NewClient(store, Config{Dir: dir, BaseURL: "https://example.com/feeds/"})
+
+	first := send(t, c, store, &clients.Message{
+		CampaignID:  "campaign",
+		CallID:      "call-1",
+		ScheduledAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+		To:          "engineering",
+		Author:      "author@example.com",
+		Subject:     "First",
+		Content:     "Hello, <world>!",
+	})
+	second := send(t, c, store, &clients.Message{
+		CampaignID:  "campaign",
+		CallID:      "call-2",
+		ScheduledAt: time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC),
+		To:          "engineering",
+		Subject:     "Second",
+		Content:     "Hello again!",
+	})
+	// Calls sent to other feeds are not included.
+	send(t, c, store, &clients.Message{
+		CampaignID:  "campaign",
+		CallID:      "call-3",
+		ScheduledAt: time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC),
+		To:          "marketing",
+		Subject:     "Third",
+		Content:     "Hello, marketing!",
+	})
+
+	assert.Equal(t, EntryID("campaign", "call-1", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)), first)
+	assert.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, first)
+
+	feed := readFeed(t, filepath.Join(dir, "engineering.xml"))
+	assert.Equal(t, "engineering", feed.Title)
+	// A version 5 UUID of "feed\x00engineering" in the namespace of ruf's feeds.
+	assert.Equal(t, "urn:uuid:23ea48b3-034e-5d8f-99c2-ad652c050826", feed.ID)
+	assert.Equal(t, "2025-01-02T09:00:00Z", feed.Updated)
+	assert.Equal(t, []atomLink{{Rel: "self", Type: "application/atom+xml", Href: "https://example.com/feeds/engineering.xml"}}, feed.Links)
+	require.Len(t, feed.Entries, 2)
+	assert.Equal(t, second, feed.Entries[0].ID)
+	assert.Equal(t, "Second", feed.Entries[0].Title)
+	assert.Nil(t, feed.Entries[0].Author)
+	assert.Equal(t, atomEntry{
+		ID:      first,
+		Title:   "First",
+		Updated: "2025-01-01T09:00:00Z",
+		Author:  &atomPerson{Name: "author@example.com", Email: "author@example.com"},
+		Content: atomText{Type: "text", Body: "Hello, <world>!"},
+	}, feed.Entries[1])
+
+	// Deleting an entry rebuilds the feed without it.
+	require.NoError(t, c.Delete("engineering", second))
+	feed = readFeed(t, filepath.Join(dir, "engineering.xml"))
+	require.Len(t, feed.Entries, 1)
+	assert.Equal(t, first, feed.Entries[0].ID)
+
+	_, err := c.Send(&clients.Message{To: "../etc/passwd", Content: "Hello!"})
+	assert.ErrorContains(t, err, "invalid feed name")
+}
+
+func TestClient_MaxEntries(t *testing.T) {
+	dir := t.TempDir()
+	store := datastore.NewMockStore()
+	c := NewClient(store, Config{Dir: dir, MaxEntries: 2})
+
+	for i := 1; i <= 3; i++ {
+		send(t, c, store, &clients.Message{
+			CampaignID:  "campaign",
+			CallID:      fmt.Sprintf("call-%d", i),
+			ScheduledAt: time.Date(2025, 1, i, 9, 0, 0, 0, time.UTC),
+			To:          "all",
+			Subject:     "Daily",
+			Content:     "Hello!",
+		})
+	}
+
+	feed := readFeed(t, filepath.Join(dir, "all.xml"))
+	require.Len(t, feed.Entries, 2)
+	assert.Equal(t, "2025-01-03T09:00:00Z", feed.Entries[0].Updated)
+	assert.Equal(t, "2025-01-02T09:00:00Z", feed.Entries[1].Updated)
+}
+
+func TestClient_ServeHTTP(t *testing.T) {
+	store := datastore.NewMockStore()
+	c := NewClient(store, Config{})
+	c.now = func() time.Time { return time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC) }
+	send(t, c, store, &clients.Message{
+		CampaignID:  "campaign",
+		CallID:      "call-1",
+		ScheduledAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+		To:          "engineering",
+		Subject:     "First",
+		Content:     "Hello!",
+	})
+
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/engineering.xml")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", resp.Header.Get("Content-Type"))
+	var feed atomFeed
+	require.NoError(t, xml.NewDecoder(resp.Body).Decode(&feed))
+	require.Len(t, feed.Entries, 1)
+	assert.Equal(t, "First", feed.Entries[0].Title)
+
+	// Feeds without entries are empty, so they can be subscribed to early.
+	feedBytes, err := c.Render("marketing")
+	require.NoError(t, err)
+	var empty atomFeed
+	require.NoError(t, xml.Unmarshal(feedBytes, &empty))
+	assert.Empty(t, empty.Entries)
+	assert.Equal(t, "2025-02-01T00:00:00Z", empty.Updated)
+
+	for _, path := range []string{"/engineering", "/../engineering.xml", "/a/b.xml"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+
+	resp, err = http.Post(server.URL+"/engineering.xml", "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
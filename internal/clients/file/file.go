@@ -0,0 +1,312 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/andrewhowdencom/ruf/internal/clients/email"
+	"gopkg.in/yaml.v3"
+)
+
+// The formats that messages are written in when the destination is a
+// directory. Messages sent to any other path are appended as JSON lines.
+const (
+	FormatMarkdown = "markdown"
+	FormatEML      = "eml"
+)
+
+// maxName is the maximum length of the name of a message file.
+const maxName = 200
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Client writes messages to the local filesystem, as an archive or for other
+// tools to consume.
+type Client struct {
+	format string
+	from   string
+	dir    string
+	now    func() time.Time
+
+	// mu serializes appends to JSON lines files.
+	mu sync.Mutex
+}
+
+// Config is the configuration of a file client.
+type Config struct {
+	// Format is the format of the files written to directories, one of
+	// FormatMarkdown (the default) or FormatEML.
+	Format string
+	// From is the address that the email destination sends from, which EML
+	// files use for their Message-ID and From headers, as that destination
+	// does.
+	From string
+	// Dir is the directory that addresses are relative to. Addresses can't
+	// leave it. If it is empty, addresses are paths on the host, which only
+	// calls from local sources can write to.
+	Dir string
+}
+
+// NewClient creates a new file client.
+func NewClient(cfg Config) *Client {
+	if cfg.Format == "" {
+		cfg.Format = FormatMarkdown
+	}
+	return &Client{
+		format: cfg.Format,
+		from:   cfg.From,
+		dir:    cfg.Dir,
+		now:    time.Now,
+	}
+}
+
+// record is a message, as written to a JSON lines file.
+type record struct {
+	CampaignID  string    `json:"campaign_id"`
+	CallID      string    `json:"call_id"`
+	ThreadID    string    `json:"thread_id,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Content     string    `json:"content"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// Send writes the message to the path in msg.To. If the path is a directory,
+// or ends with a path separator, the message is written to a new file in it
+// and the path of that file is returned. Otherwise, the message is appended
+// to the path as a JSON line.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	if msg.To == "" {
+		return "", fmt.Errorf("no path to write to")
+	}
+	// Anyone who can change a remote source could otherwise write to any file
+	// that the worker can, so its calls can only write inside the directory.
+	if c.dir == "" && !isLocal(msg.SourceURL) {
+		return "", fmt.Errorf("file destinations in remote sources need file.dir to be set")
+	}
+	to, err := c.resolve(msg.To)
+	if err != nil {
+		return "", err
+	}
+
+	if isDir(to) {
+		return c.write(to, msg)
+	}
+	return "", c.append(to, msg)
+}
+
+// Delete removes a message file that was written to a directory. Messages
+// appended to JSON lines files are an archive, and are never removed.
+func (c *Client) Delete(to, path string) error {
+	to, err := c.resolve(to)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(to, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("file '%s' is not in '%s'", path, to)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+	return nil
+}
+
+// resolve returns the path on the host for an address, which is relative to
+// the directory if there is one.
+func (c *Client) resolve(to string) (string, error) {
+	if c.dir == "" {
+		return to, nil
+	}
+	if filepath.IsAbs(to) || slices.Contains(strings.FieldsFunc(to, isSeparator), "..") {
+		return "", fmt.Errorf("invalid path '%s': must be relative to file.dir, without '..'", to)
+	}
+	path := filepath.Join(c.dir, to)
+	if rel, err := filepath.Rel(c.dir, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid path '%s': must be in file.dir", to)
+	}
+	// Join drops the trailing separator that marks a directory.
+	if isSeparator(rune(to[len(to)-1])) {
+		path += string(filepath.Separator)
+	}
+	return path, nil
+}
+
+func (c *Client) append(to string, msg *clients.Message) error {
+	line, err := json.Marshal(record{
+		CampaignID:  msg.CampaignID,
+		CallID:      msg.CallID,
+		ThreadID:    msg.ThreadID,
+		Author:      msg.Author,
+		Subject:     msg.Subject,
+		Content:     msg.Content,
+		ScheduledAt: msg.ScheduledAt,
+		SentAt:      c.now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.OpenFile(to, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return f.Close()
+}
+
+func (c *Client) write(dir string, msg *clients.Message) (string, error) {
+	var content []byte
+	var ext string
+	var err error
+	switch c.format {
+	case FormatMarkdown:
+		content, err = c.markdown(msg)
+		ext = ".md"
+	case FormatEML:
+		content, err = c.eml(msg)
+		ext = ".eml"
+	default:
+		return "", fmt.Errorf("unsupported file format '%s'", c.format)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Files are named after the occurrence, so that they sort by the time
+	// they were scheduled at and a retried send overwrites the same file.
+	name := unsafeChars.ReplaceAllString(fmt.Sprintf("%s-%s-%s",
+		msg.ScheduledAt.UTC().Format("20060102T150405Z"), msg.CampaignID, msg.CallID), "_")
+	if len(name) > maxName {
+		name = name[:maxName]
+	}
+	path := filepath.Join(dir, name+ext)
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return path, nil
+}
+
+// markdown renders the message as a Markdown document with YAML front matter.
+func (c *Client) markdown(msg *clients.Message) ([]byte, error) {
+	frontMatter, err := yaml.Marshal(struct {
+		CampaignID  string    `yaml:"campaign_id"`
+		CallID      string    `yaml:"call_id"`
+		ThreadID    string    `yaml:"thread_id,omitempty"`
+		Author      string    `yaml:"author,omitempty"`
+		ScheduledAt time.Time `yaml:"scheduled_at"`
+		SentAt      time.Time `yaml:"sent_at"`
+	}{
+		CampaignID:  msg.CampaignID,
+		CallID:      msg.CallID,
+		ThreadID:    msg.ThreadID,
+		Author:      msg.Author,
+		ScheduledAt: msg.ScheduledAt,
+		SentAt:      c.now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal front matter: %w", err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(frontMatter)
+	b.WriteString("---\n\n")
+	if msg.Subject != "" {
+		fmt.Fprintf(&b, "# %s\n\n", msg.Subject)
+	}
+	b.WriteString(msg.Content)
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// eml renders the message as an RFC 5322 message, with the same Message-ID and
+// threading headers as the email destination.
+func (c *Client) eml(msg *clients.Message) ([]byte, error) {
+	headers := [][2]string{
+		{"Date", c.now().UTC().Format(time.RFC1123Z)},
+	}
+	from := c.from
+	if msg.Author != "" {
+		from = msg.Author
+		headers = append(headers, [2]string{"Reply-To", msg.Author})
+	}
+	if from != "" {
+		headers = append(headers, [2]string{"From", from})
+	}
+	headers = append(headers,
+		[2]string{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		[2]string{"Message-ID", email.MessageID(c.from, msg.CampaignID, msg.CallID)},
+	)
+	if msg.ThreadID != "" && msg.ThreadID != msg.CallID {
+		parent := email.MessageID(c.from, msg.CampaignID, msg.ThreadID)
+		headers = append(headers,
+			[2]string{"In-Reply-To", parent},
+			[2]string{"References", parent},
+		)
+	}
+	headers = append(headers,
+		[2]string{"X-Ruf-Campaign", msg.CampaignID},
+		[2]string{"X-Ruf-Call", msg.CallID},
+		[2]string{"MIME-Version", "1.0"},
+		[2]string{"Content-Type", "text/plain; charset=UTF-8"},
+		[2]string{"Content-Transfer-Encoding", "8bit"},
+	)
+
+	var b bytes.Buffer
+	for _, h := range headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", h[0], h[1])
+	}
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Content, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes(), nil
+}
+
+// isLocal reports whether a source is read from the worker's host.
+func isLocal(sourceURL string) bool {
+	u, err := url.Parse(sourceURL)
+	return err == nil && (u.Scheme == "file" || u.Scheme == "git+file")
+}
+
+// isSeparator reports whether r separates the elements of a path.
+func isSeparator(r rune) bool {
+	return r == '/' || os.IsPathSeparator(uint8(r))
+}
+
+// isDir reports whether path refers to a directory, either because it ends
+// with a path separator or because a directory exists there.
+func isDir(path string) bool {
+	if path != "" && isSeparator(rune(path[len(path)-1])) {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
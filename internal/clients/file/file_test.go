@@ -0,0 +1,195 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/andrewhowdencom/ruf/internal/clients/email"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	scheduledAt = time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	sentAt      = time.Date(2025, 1, 2, 9, 0, 30, 0, time.UTC)
+)
+
+// localSource is the URL of a source on the worker's host, which can write to
+// any path.
+const localSource = "file:///campaigns/calls.yaml"
+
+func newTestClient(format string) *Client {
+	c := NewClient(Config{Format: format, From: "ruf@example.com"})
+	c.now = func() time.Time { return sentAt }
+	return c
+}
+
+func TestClient_SendJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive", "calls.jsonl")
+	c := newTestClient("")
+
+	for _, id := range []string{"call-1", "call-2"} {
+		ref, err := c.Send(&clients.Message{
+			CampaignID:  "campaign",
+			CallID:      id,
+			ScheduledAt: scheduledAt,
+			SourceURL:   localSource,
+			To:          path,
+			Author:      "author@example.com",
+			Subject:     "Hello!",
+			Content:     "Hello,\nworld!",
+		})
+		require.NoError(t, err)
+		assert.Empty(t, ref)
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, records, 2)
+	assert.Equal(t, record{
+		CampaignID:  "campaign",
+		CallID:      "call-1",
+		Author:      "author@example.com",
+		Subject:     "Hello!",
+		Content:     "Hello,\nworld!",
+		ScheduledAt: scheduledAt,
+		SentAt:      sentAt,
+	}, records[0])
+	assert.Equal(t, "call-2", records[1].CallID)
+}
+
+func TestClient_SendMarkdownAndDelete(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive") + string(filepath.Separator)
+	c := newTestClient(FormatMarkdown)
+
+	path, err := c.Send(&clients.Message{
+		CampaignID:  "campaign",
+		CallID:      "call-1:cron:0 9 * * *",
+		ScheduledAt: scheduledAt,
+		SourceURL:   localSource,
+		To:          dir,
+		Author:      "author@example.com",
+		Subject:     "Hello!",
+		Content:     "Hello, world!",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "20250102T090000Z-campaign-call-1_cron_0_9_.md"), path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `---
+campaign_id: campaign
+call_id: call-1:cron:0 9 * * *
+author: author@example.com
+scheduled_at: 2025-01-02T09:00:00Z
+sent_at: 2025-01-02T09:00:30Z
+---
+
+# Hello!
+
+Hello, world!
+`, string(content))
+
+	assert.ErrorContains(t, c.Delete(dir, "/etc/passwd"), "is not in")
+	require.NoError(t, c.Delete(dir, path))
+	assert.NoFileExists(t, path)
+}
+
+func TestClient_SendEML(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestClient(FormatEML)
+
+	path, err := c.Send(&clients.Message{
+		CampaignID:  "campaign",
+		CallID:      "call-2",
+		ThreadID:    "call-1",
+		ScheduledAt: scheduledAt,
+		SourceURL:   localSource,
+		To:          dir,
+		Author:      "author@example.com",
+		Subject:     "Grüße",
+		Content:     "Hello,\nworld!",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ".eml", filepath.Ext(path))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Date: Thu, 02 Jan 2025 09:00:30 +0000\r\nReply-To: author@example.com\r\nFrom: author@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n")
+	// The Message-ID is the same as the email destination's, so that archived
+	// messages can be matched up with the emails sent for the same call.
+	assert.Contains(t, string(content), "Message-ID: "+email.MessageID("ruf@example.com", "campaign", "call-2")+"\r\nIn-Reply-To: "+email.MessageID("ruf@example.com", "campaign", "call-1")+"\r\n")
+	assert.Contains(t, string(content), "X-Ruf-Call: call-2\r\n")
+	assert.Contains(t, string(content), "\r\n\r\nHello,\r\nworld!\r\n")
+}
+
+func TestClient_SendErrors(t *testing.T) {
+	c := newTestClient("pdf")
+
+	_, err := c.Send(&clients.Message{To: t.TempDir(), SourceURL: localSource, Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "unsupported file format 'pdf'")
+
+	_, err = c.Send(&clients.Message{Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "no path to write to")
+}
+
+func TestClient_Dir(t *testing.T) {
+	root := t.TempDir()
+	c := NewClient(Config{Dir: root})
+	c.now = func() time.Time { return sentAt }
+
+	// Addresses are relative to the directory, whatever the source.
+	msg := &clients.Message{CampaignID: "campaign", CallID: "call-1", ScheduledAt: scheduledAt, SourceURL: "https://example.com/calls.yaml", Content: "Hello, world!"}
+	msg.To = "archive/"
+	path, err := c.Send(msg)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "archive", "20250102T090000Z-campaign-call-1.md"), path)
+	require.NoError(t, c.Delete("archive/", path))
+	assert.NoFileExists(t, path)
+
+	msg.To = "calls.jsonl"
+	_, err = c.Send(msg)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(root, "calls.jsonl"))
+
+	// Addresses can't leave the directory.
+	for _, to := range []string{"/etc/cron.d/ruf", "../calls.jsonl", "archive/../../calls.jsonl"} {
+		msg.To = to
+		_, err = c.Send(msg)
+		assert.ErrorContains(t, err, "invalid path", to)
+	}
+	assert.ErrorContains(t, c.Delete("../", filepath.Join(root, "calls.jsonl")), "invalid path")
+}
+
+func TestClient_RemoteSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.jsonl")
+	c := newTestClient("")
+
+	// Without a directory, only local sources can write to the host.
+	for _, source := range []string{"https://example.com/calls.yaml", "git+ssh://git.example.com/repo.git//calls.yaml", "s3://bucket/calls.yaml", ""} {
+		_, err := c.Send(&clients.Message{To: path, SourceURL: source, Content: "Hello, world!"})
+		assert.ErrorContains(t, err, "file destinations in remote sources need file.dir to be set", source)
+	}
+	assert.NoFileExists(t, path)
+
+	_, err := c.Send(&clients.Message{To: path, SourceURL: "git+file:///srv/calls.git//calls.yaml", Content: "Hello, world!"})
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+}
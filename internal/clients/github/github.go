@@ -0,0 +1,348 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// DefaultHost is the host of addresses that don't name one.
+const DefaultHost = "github.com"
+
+// Client posts messages to GitHub, as discussions in a category or as
+// comments on issues and pull requests, through the GraphQL API.
+type Client struct {
+	httpClient *http.Client
+	apiURLs    map[string]string
+	token      func(host string) string
+
+	mu         sync.Mutex
+	categories map[string]category
+}
+
+// category identifies a discussion category in a repository.
+type category struct {
+	repoID string
+	id     string
+}
+
+// NewClient creates a new GitHub client. APIURLs maps hosts to the URL of
+// their GraphQL API, for GitHub Enterprise servers that don't serve it at
+// "https://<host>/api/graphql". Token returns the token for a host.
+func NewClient(apiURLs map[string]string, token func(host string) string) *Client {
+	urls := make(map[string]string, len(apiURLs))
+	for host, u := range apiURLs {
+		urls[strings.ToLower(host)] = u
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		apiURLs:    urls,
+		token:      token,
+		categories: make(map[string]category),
+	}
+}
+
+// Address is a parsed destination address. Addresses are either
+// "[host/]owner/repo#number", for a comment on an issue or pull request, or
+// "[host/]owner/repo/discussions/category", for a discussion in a category.
+type Address struct {
+	Host     string
+	Owner    string
+	Repo     string
+	Number   int
+	Category string
+}
+
+// ParseAddress parses a destination address.
+func ParseAddress(to string) (*Address, error) {
+	parts := strings.Split(to, "/")
+	a := &Address{Host: DefaultHost}
+	if len(parts) > 0 && strings.Contains(parts[0], ".") {
+		a.Host, parts = strings.ToLower(parts[0]), parts[1:]
+	}
+
+	switch {
+	case len(parts) == 2 && strings.Contains(parts[1], "#"):
+		repo, number, _ := strings.Cut(parts[1], "#")
+		n, err := strconv.Atoi(number)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid github address '%s': invalid issue number '%s'", to, number)
+		}
+		a.Owner, a.Repo, a.Number = parts[0], repo, n
+	case len(parts) == 4 && parts[2] == "discussions":
+		a.Owner, a.Repo, a.Category = parts[0], parts[1], parts[3]
+	default:
+		return nil, fmt.Errorf("invalid github address '%s': expected owner/repo#number or owner/repo/discussions/category", to)
+	}
+
+	if a.Owner == "" || a.Repo == "" || (a.Number == 0 && a.Category == "") {
+		return nil, fmt.Errorf("invalid github address '%s'", to)
+	}
+	return a, nil
+}
+
+// Send creates a discussion or a comment for the message, and returns its
+// node ID. Calls after the first one in a sequence are posted as comments on
+// the discussion that the first one created.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	addr, err := ParseAddress(msg.To)
+	if err != nil {
+		return "", err
+	}
+
+	body := msg.Content
+	if msg.Author != "" {
+		body = fmt.Sprintf("%s\n\n---\nThx: %s", body, msg.Author)
+	}
+
+	switch {
+	case addr.Category != "" && msg.ReplyTo != "":
+		var out struct {
+			AddDiscussionComment struct {
+				Comment struct {
+					ID string `json:"id"`
+				} `json:"comment"`
+			} `json:"addDiscussionComment"`
+		}
+		err := c.do(addr.Host, `mutation($discussionId: ID!, $body: String!) {
+  addDiscussionComment(input: {discussionId: $discussionId, body: $body}) { comment { id } }
+}`, map[string]any{"discussionId": msg.ReplyTo, "body": heading(msg.Subject, body)}, &out)
+		if err != nil {
+			return "", fmt.Errorf("failed to add discussion comment: %w", err)
+		}
+		return out.AddDiscussionComment.Comment.ID, nil
+
+	case addr.Category != "":
+		cat, err := c.category(addr)
+		if err != nil {
+			return "", err
+		}
+		var out struct {
+			CreateDiscussion struct {
+				Discussion struct {
+					ID string `json:"id"`
+				} `json:"discussion"`
+			} `json:"createDiscussion"`
+		}
+		err = c.do(addr.Host, `mutation($repositoryId: ID!, $categoryId: ID!, $title: String!, $body: String!) {
+  createDiscussion(input: {repositoryId: $repositoryId, categoryId: $categoryId, title: $title, body: $body}) { discussion { id } }
+}`, map[string]any{"repositoryId": cat.repoID, "categoryId": cat.id, "title": msg.Subject, "body": body}, &out)
+		if err != nil {
+			return "", fmt.Errorf("failed to create discussion: %w", err)
+		}
+		return out.CreateDiscussion.Discussion.ID, nil
+
+	default:
+		var subject struct {
+			Repository struct {
+				IssueOrPullRequest *struct {
+					ID string `json:"id"`
+				} `json:"issueOrPullRequest"`
+			} `json:"repository"`
+		}
+		err := c.do(addr.Host, `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    issueOrPullRequest(number: $number) { ... on Issue { id } ... on PullRequest { id } }
+  }
+}`, map[string]any{"owner": addr.Owner, "name": addr.Repo, "number": addr.Number}, &subject)
+		if err != nil {
+			return "", fmt.Errorf("failed to get issue: %w", err)
+		}
+		if subject.Repository.IssueOrPullRequest == nil {
+			return "", fmt.Errorf("issue or pull request '%s' not found", msg.To)
+		}
+
+		var out struct {
+			AddComment struct {
+				CommentEdge struct {
+					Node struct {
+						ID string `json:"id"`
+					} `json:"node"`
+				} `json:"commentEdge"`
+			} `json:"addComment"`
+		}
+		err = c.do(addr.Host, `mutation($subjectId: ID!, $body: String!) {
+  addComment(input: {subjectId: $subjectId, body: $body}) { commentEdge { node { id } } }
+}`, map[string]any{"subjectId": subject.Repository.IssueOrPullRequest.ID, "body": heading(msg.Subject, body)}, &out)
+		if err != nil {
+			return "", fmt.Errorf("failed to add comment: %w", err)
+		}
+		return out.AddComment.CommentEdge.Node.ID, nil
+	}
+}
+
+// Delete deletes a discussion or comment by its node ID.
+func (c *Client) Delete(to, id string) error {
+	addr, err := ParseAddress(to)
+	if err != nil {
+		return err
+	}
+
+	var node struct {
+		Node *struct {
+			TypeName string `json:"__typename"`
+		} `json:"node"`
+	}
+	if err := c.do(addr.Host, `query($id: ID!) { node(id: $id) { __typename } }`, map[string]any{"id": id}, &node); err != nil {
+		return fmt.Errorf("failed to get node: %w", err)
+	}
+	if node.Node == nil {
+		return fmt.Errorf("node '%s' not found", id)
+	}
+
+	var mutation string
+	switch node.Node.TypeName {
+	case "Discussion":
+		mutation = "deleteDiscussion"
+	case "DiscussionComment":
+		mutation = "deleteDiscussionComment"
+	case "IssueComment":
+		mutation = "deleteIssueComment"
+	default:
+		return fmt.Errorf("cannot delete node '%s' of type %s", id, node.Node.TypeName)
+	}
+
+	query := fmt.Sprintf(`mutation($id: ID!) { %s(input: {id: $id}) { clientMutationId } }`, mutation)
+	if err := c.do(addr.Host, query, map[string]any{"id": id}, nil); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", node.Node.TypeName, err)
+	}
+	return nil
+}
+
+// category returns the discussion category of an address. The category is
+// matched by its name or slug.
+func (c *Client) category(addr *Address) (category, error) {
+	key := strings.ToLower(strings.Join([]string{addr.Host, addr.Owner, addr.Repo, addr.Category}, "/"))
+	c.mu.Lock()
+	cat, ok := c.categories[key]
+	c.mu.Unlock()
+	if ok {
+		return cat, nil
+	}
+
+	var out struct {
+		Repository *struct {
+			ID                   string `json:"id"`
+			DiscussionCategories struct {
+				Nodes []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+					Slug string `json:"slug"`
+				} `json:"nodes"`
+			} `json:"discussionCategories"`
+		} `json:"repository"`
+	}
+	err := c.do(addr.Host, `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    id
+    discussionCategories(first: 100) { nodes { id name slug } }
+  }
+}`, map[string]any{"owner": addr.Owner, "name": addr.Repo}, &out)
+	if err != nil {
+		return category{}, fmt.Errorf("failed to get discussion categories: %w", err)
+	}
+	if out.Repository == nil {
+		return category{}, fmt.Errorf("repository '%s/%s' not found", addr.Owner, addr.Repo)
+	}
+
+	for _, node := range out.Repository.DiscussionCategories.Nodes {
+		if strings.EqualFold(node.Name, addr.Category) || strings.EqualFold(node.Slug, addr.Category) {
+			cat := category{repoID: out.Repository.ID, id: node.ID}
+			c.mu.Lock()
+			c.categories[key] = cat
+			c.mu.Unlock()
+			return cat, nil
+		}
+	}
+	return category{}, fmt.Errorf("discussion category '%s' not found in '%s/%s'", addr.Category, addr.Owner, addr.Repo)
+}
+
+// apiURL returns the URL of the GraphQL API for a host.
+func (c *Client) apiURL(host string) string {
+	if u, ok := c.apiURLs[host]; ok {
+		return u
+	}
+	if host == DefaultHost {
+		return "https://api.github.com/graphql"
+	}
+	// GitHub Enterprise Server serves the API under the same host.
+	return fmt.Sprintf("https://%s/api/graphql", host)
+}
+
+// do runs a GraphQL query against the API of a host, decoding its data into
+// out if it is not nil.
+func (c *Client) do(host, query string, variables map[string]any, out any) error {
+	token := ""
+	if c.token != nil {
+		token = c.token(host)
+	}
+	if token == "" {
+		return fmt.Errorf("no token configured for %s", host)
+	}
+
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.apiURL(host), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, apiErr.Message)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		var messages []string
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return fmt.Errorf("failed to decode data: %w", err)
+		}
+	}
+	return nil
+}
+
+// heading prefixes a comment body with the subject, as comments have no title.
+func heading(subject, body string) string {
+	if subject == "" {
+		return body
+	}
+	return fmt.Sprintf("### %s\n\n%s", subject, body)
+}
@@ -0,0 +1,256 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deleteMutation = regexp.MustCompile(`delete\w+`)
+
+// fakeGitHub is a fake of the parts of the GraphQL API used by the client.
+type fakeGitHub struct {
+	mu         sync.Mutex
+	requests   []map[string]any
+	categories int
+	deleted    []string
+}
+
+func newFakeGitHub(t *testing.T) (*httptest.Server, *fakeGitHub) {
+	t.Helper()
+
+	f := &fakeGitHub{}
+	types := map[string]string{
+		"D_1":  "Discussion",
+		"DC_1": "DiscussionComment",
+		"IC_1": "IssueComment",
+		"I_1":  "Issue",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/graphql", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Bad credentials"}`))
+			return
+		}
+
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.requests = append(f.requests, req.Variables)
+
+		var data any
+		switch {
+		case strings.Contains(req.Query, "discussionCategories"):
+			f.categories++
+			if req.Variables["name"] != "ruf" {
+				data = map[string]any{"repository": nil}
+				break
+			}
+			data = map[string]any{"repository": map[string]any{
+				"id": "R_1",
+				"discussionCategories": map[string]any{"nodes": []any{
+					map[string]any{"id": "DIC_1", "name": "General", "slug": "general"},
+					map[string]any{"id": "DIC_2", "name": "Release Notes", "slug": "release-notes"},
+				}},
+			}}
+		case strings.Contains(req.Query, "createDiscussion"):
+			data = map[string]any{"createDiscussion": map[string]any{"discussion": map[string]any{"id": "D_1"}}}
+		case strings.Contains(req.Query, "addDiscussionComment"):
+			data = map[string]any{"addDiscussionComment": map[string]any{"comment": map[string]any{"id": "DC_1"}}}
+		case strings.Contains(req.Query, "issueOrPullRequest"):
+			if req.Variables["number"] != float64(12) {
+				data = map[string]any{"repository": map[string]any{"issueOrPullRequest": nil}}
+				break
+			}
+			data = map[string]any{"repository": map[string]any{"issueOrPullRequest": map[string]any{"id": "I_1"}}}
+		case strings.Contains(req.Query, "addComment"):
+			data = map[string]any{"addComment": map[string]any{"commentEdge": map[string]any{"node": map[string]any{"id": "IC_1"}}}}
+		case strings.Contains(req.Query, "node(id"):
+			typeName, ok := types[req.Variables["id"].(string)]
+			if !ok {
+				json.NewEncoder(w).Encode(map[string]any{
+					"data":   map[string]any{"node": nil},
+					"errors": []any{map[string]any{"message": "Could not resolve to a node with the global id of '" + req.Variables["id"].(string) + "'"}},
+				})
+				return
+			}
+			data = map[string]any{"node": map[string]any{"__typename": typeName}}
+		case strings.Contains(req.Query, "delete"):
+			f.deleted = append(f.deleted, deleteMutation.FindString(req.Query)+" "+req.Variables["id"].(string))
+			data = map[string]any{}
+		default:
+			t.Errorf("unexpected query: %s", req.Query)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, f
+}
+
+func newTestClient(server *httptest.Server) *Client {
+	return NewClient(
+		map[string]string{"GitHub.example.com": server.URL + "/api/graphql"},
+		func(host string) string {
+			if host == "github.example.com" {
+				return "gh-token"
+			}
+			return ""
+		},
+	)
+}
+
+func TestClient_SendDiscussion(t *testing.T) {
+	server, f := newFakeGitHub(t)
+	c := newTestClient(server)
+
+	id, err := c.Send(&clients.Message{
+		To:      "github.example.com/andrewhowdencom/ruf/discussions/release-notes",
+		Author:  "author@example.com",
+		Subject: "v1.0.0",
+		Content: "We released v1.0.0!",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "D_1", id)
+
+	reply, err := c.Send(&clients.Message{
+		To:      "github.example.com/andrewhowdencom/ruf/discussions/Release Notes",
+		Subject: "Reminder",
+		Content: "Upgrade now!",
+		ReplyTo: id,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "DC_1", reply)
+
+	// Categories are cached, so a second discussion doesn't look them up again.
+	_, err = c.Send(&clients.Message{
+		To:      "github.example.com/andrewhowdencom/ruf/discussions/release-notes",
+		Subject: "v1.0.1",
+		Content: "We released v1.0.1!",
+	})
+	require.NoError(t, err)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, 1, f.categories)
+	require.Len(t, f.requests, 4)
+	assert.Equal(t, map[string]any{
+		"repositoryId": "R_1",
+		"categoryId":   "DIC_2",
+		"title":        "v1.0.0",
+		"body":         "We released v1.0.0!\n\n---\nThx: author@example.com",
+	}, f.requests[1])
+	assert.Equal(t, map[string]any{
+		"discussionId": "D_1",
+		"body":         "### Reminder\n\nUpgrade now!",
+	}, f.requests[2])
+}
+
+func TestClient_SendComment(t *testing.T) {
+	server, f := newFakeGitHub(t)
+	c := newTestClient(server)
+
+	id, err := c.Send(&clients.Message{
+		To:      "github.example.com/andrewhowdencom/ruf#12",
+		Subject: "Heads up",
+		Content: "This ships tomorrow.",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "IC_1", id)
+
+	f.mu.Lock()
+	require.Len(t, f.requests, 2)
+	assert.Equal(t, map[string]any{"owner": "andrewhowdencom", "name": "ruf", "number": float64(12)}, f.requests[0])
+	assert.Equal(t, map[string]any{"subjectId": "I_1", "body": "### Heads up\n\nThis ships tomorrow."}, f.requests[1])
+	f.mu.Unlock()
+
+	_, err = c.Send(&clients.Message{To: "github.example.com/andrewhowdencom/ruf#13", Content: "Hello!"})
+	assert.ErrorContains(t, err, "issue or pull request 'github.example.com/andrewhowdencom/ruf#13' not found")
+}
+
+func TestClient_Delete(t *testing.T) {
+	server, f := newFakeGitHub(t)
+	c := newTestClient(server)
+
+	to := "github.example.com/andrewhowdencom/ruf/discussions/general"
+	require.NoError(t, c.Delete(to, "D_1"))
+	require.NoError(t, c.Delete(to, "DC_1"))
+	require.NoError(t, c.Delete("github.example.com/andrewhowdencom/ruf#12", "IC_1"))
+
+	assert.ErrorContains(t, c.Delete(to, "I_1"), "cannot delete node 'I_1' of type Issue")
+	assert.ErrorContains(t, c.Delete(to, "X_1"), "Could not resolve to a node")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, []string{
+		"deleteDiscussion D_1",
+		"deleteDiscussionComment DC_1",
+		"deleteIssueComment IC_1",
+	}, f.deleted)
+}
+
+func TestClient_Errors(t *testing.T) {
+	server, _ := newFakeGitHub(t)
+	c := newTestClient(server)
+
+	_, err := c.Send(&clients.Message{To: "github.example.com/andrewhowdencom/other/discussions/general", Content: "Hello!"})
+	assert.ErrorContains(t, err, "repository 'andrewhowdencom/other' not found")
+
+	_, err = c.Send(&clients.Message{To: "github.example.com/andrewhowdencom/ruf/discussions/missing", Content: "Hello!"})
+	assert.ErrorContains(t, err, "discussion category 'missing' not found")
+
+	_, err = c.Send(&clients.Message{To: "andrewhowdencom/ruf#12", Content: "Hello!"})
+	assert.ErrorContains(t, err, "no token configured for github.com")
+
+	c = NewClient(map[string]string{"github.example.com": server.URL + "/api/graphql"}, func(string) string { return "wrong" })
+	_, err = c.Send(&clients.Message{To: "github.example.com/andrewhowdencom/ruf#12", Content: "Hello!"})
+	assert.ErrorContains(t, err, "status code 401: Bad credentials")
+}
+
+func TestParseAddress(t *testing.T) {
+	testCases := []struct {
+		to       string
+		expected *Address
+		err      string
+	}{
+		{"andrewhowdencom/ruf#12", &Address{Host: "github.com", Owner: "andrewhowdencom", Repo: "ruf", Number: 12}, ""},
+		{"GHE.example.com/org/repo/discussions/Announcements", &Address{Host: "ghe.example.com", Owner: "org", Repo: "repo", Category: "Announcements"}, ""},
+		{"andrewhowdencom/ruf#abc", nil, "invalid issue number"},
+		{"andrewhowdencom/ruf", nil, "expected owner/repo#number"},
+		{"andrewhowdencom/ruf/issues/12", nil, "expected owner/repo#number"},
+		{"/ruf#12", nil, "invalid github address"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.to, func(t *testing.T) {
+			addr, err := ParseAddress(tc.to)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, addr)
+		})
+	}
+}
+
+func TestClient_APIURL(t *testing.T) {
+	c := NewClient(map[string]string{"proxy.example.com": "https://proxy.example.com/graphql"}, nil)
+	assert.Equal(t, "https://api.github.com/graphql", c.apiURL("github.com"))
+	assert.Equal(t, "https://ghe.example.com/api/graphql", c.apiURL("ghe.example.com"))
+	assert.Equal(t, "https://proxy.example.com/graphql", c.apiURL("proxy.example.com"))
+}
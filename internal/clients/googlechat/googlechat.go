@@ -0,0 +1,145 @@
+package googlechat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// The formats that messages can be posted in.
+const (
+	FormatCard = "card"
+	FormatText = "text"
+)
+
+// Client posts messages to Google Chat spaces through incoming webhooks. It
+// doesn't implement clients.Deleter: deleting a message needs the Chat API,
+// authenticated as a user or app, which webhooks don't have.
+type Client struct {
+	httpClient *http.Client
+	webhooks   clients.Webhooks
+	format     string
+}
+
+// NewClient creates a new Google Chat client. Webhooks maps aliases that can
+// be used as destination addresses to webhook URLs, and format is one of
+// FormatCard (the default) or FormatText.
+func NewClient(webhooks map[string]string, format string) *Client {
+	if format == "" {
+		format = FormatCard
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		webhooks:   clients.NewWebhooks(webhooks),
+		format:     format,
+	}
+}
+
+// Send posts the message to the webhook, in the thread of its sequence if it
+// has one, and returns the resource name of the message, such as
+// "spaces/AAAA/messages/BBBB".
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	webhookURL, err := c.webhooks.Resolve(msg.To)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve google chat webhook: %w", err)
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse google chat webhook url: %w", err)
+	}
+	if msg.ThreadID != "" {
+		// Every call in a sequence uses the same thread key, so Google Chat
+		// starts the thread with the first one and replies with the rest.
+		q := u.Query()
+		q.Set("threadKey", msg.CampaignID+"/"+msg.ThreadID)
+		q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+		u.RawQuery = q.Encode()
+	}
+
+	var payload map[string]any
+	switch c.format {
+	case FormatCard:
+		payload = card(msg)
+	case FormatText:
+		payload = text(msg)
+	default:
+		return "", fmt.Errorf("unsupported google chat format '%s'", c.format)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	resp, err := c.httpClient.Post(u.String(), "application/json; charset=UTF-8", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to post to google chat webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return "", fmt.Errorf("failed to post to google chat webhook: status code %d: %s", resp.StatusCode, apiErr.Error.Message)
+	}
+
+	var created struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode google chat message: %w", err)
+	}
+	return created.Name, nil
+}
+
+// text builds a plain text message, with the subject in bold.
+func text(msg *clients.Message) map[string]any {
+	t := msg.Content
+	if msg.Subject != "" {
+		t = fmt.Sprintf("*%s*\n%s", msg.Subject, t)
+	}
+	if msg.Author != "" {
+		t = fmt.Sprintf("%s\n\n_Thx: %s_", t, msg.Author)
+	}
+	return map[string]any{"text": t}
+}
+
+// card builds a card message, with the subject as the header of the card.
+func card(msg *clients.Message) map[string]any {
+	widgets := []map[string]any{
+		{"textParagraph": map[string]any{"text": msg.Content}},
+	}
+	if msg.Author != "" {
+		widgets = append(widgets, map[string]any{
+			"decoratedText": map[string]any{"text": "Thx: " + msg.Author},
+		})
+	}
+
+	c := map[string]any{
+		"sections": []map[string]any{{"widgets": widgets}},
+	}
+	if msg.Subject != "" {
+		c["header"] = map[string]any{"title": msg.Subject}
+	}
+
+	return map[string]any{
+		"cardsV2": []map[string]any{
+			{
+				"cardId": strings.Trim(msg.CampaignID+"-"+msg.CallID, "-"),
+				"card":   c,
+			},
+		},
+	}
+}
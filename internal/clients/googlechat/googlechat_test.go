@@ -0,0 +1,104 @@
+package googlechat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SendCardInThread(t *testing.T) {
+	var query url.Values
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/spaces/AAAA/messages", r.URL.Path)
+		query = r.URL.Query()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"name": "spaces/AAAA/messages/BBBB.BBBB", "thread": {"name": "spaces/AAAA/threads/CCCC"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(map[string]string{"Partners": server.URL + "/v1/spaces/AAAA/messages?key=k&token=t"}, "")
+
+	name, err := c.Send(&clients.Message{
+		CampaignID: "campaign",
+		CallID:     "call-2",
+		ThreadID:   "call-1",
+		To:         "partners",
+		Author:     "author@example.com",
+		Subject:    "Hello!",
+		Content:    "Hello, world!",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "spaces/AAAA/messages/BBBB.BBBB", name)
+
+	assert.Equal(t, "k", query.Get("key"))
+	assert.Equal(t, "t", query.Get("token"))
+	assert.Equal(t, "campaign/call-1", query.Get("threadKey"))
+	assert.Equal(t, "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD", query.Get("messageReplyOption"))
+
+	cards := received["cardsV2"].([]any)
+	require.Len(t, cards, 1)
+	card := cards[0].(map[string]any)
+	assert.Equal(t, "campaign-call-2", card["cardId"])
+	assert.Equal(t, map[string]any{
+		"header": map[string]any{"title": "Hello!"},
+		"sections": []any{
+			map[string]any{
+				"widgets": []any{
+					map[string]any{"textParagraph": map[string]any{"text": "Hello, world!"}},
+					map[string]any{"decoratedText": map[string]any{"text": "Thx: author@example.com"}},
+				},
+			},
+		},
+	}, card["card"])
+}
+
+func TestClient_SendText(t *testing.T) {
+	var query url.Values
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"name": "spaces/AAAA/messages/DDDD"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(nil, FormatText)
+
+	name, err := c.Send(&clients.Message{
+		To:      server.URL,
+		Author:  "author@example.com",
+		Subject: "Hello!",
+		Content: "Hello, world!",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "spaces/AAAA/messages/DDDD", name)
+	assert.Empty(t, query.Get("threadKey"))
+	assert.Equal(t, map[string]any{"text": "*Hello!*\nHello, world!\n\n_Thx: author@example.com_"}, received)
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"code": 400, "message": "Invalid JSON payload received.", "status": "INVALID_ARGUMENT"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(map[string]string{"broken": server.URL}, "")
+
+	_, err := c.Send(&clients.Message{To: "broken", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 400: Invalid JSON payload received.")
+
+	_, err = c.Send(&clients.Message{To: "unknown", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "webhook 'unknown' not found")
+
+	c = NewClient(map[string]string{"broken": server.URL}, "markdown")
+	_, err = c.Send(&clients.Message{To: "broken", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "unsupported google chat format 'markdown'")
+}
@@ -0,0 +1,117 @@
+package gotify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// Client pushes messages to Gotify applications.
+type Client struct {
+	httpClient *http.Client
+	server     string
+	apps       map[string]string
+}
+
+// NewClient creates a new Gotify client for the server. Apps maps the names
+// that can be used as destination addresses to application tokens.
+func NewClient(server string, apps map[string]string) *Client {
+	tokens := make(map[string]string, len(apps))
+	for name, token := range apps {
+		tokens[strings.ToLower(name)] = token
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		server:     strings.TrimSuffix(server, "/"),
+		apps:       tokens,
+	}
+}
+
+type message struct {
+	Title    string         `json:"title,omitempty"`
+	Message  string         `json:"message"`
+	Priority *int           `json:"priority,omitempty"`
+	Extras   map[string]any `json:"extras"`
+}
+
+// Send pushes the message to the application in msg.To, and returns the ID of
+// the message.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	if c.server == "" {
+		return "", fmt.Errorf("no gotify server configured")
+	}
+	token, ok := c.apps[strings.ToLower(msg.To)]
+	if !ok {
+		return "", fmt.Errorf("gotify app '%s' not found", msg.To)
+	}
+
+	content := msg.Content
+	if msg.Author != "" {
+		content = fmt.Sprintf("%s\n\n---\nThx: %s", content, msg.Author)
+	}
+
+	m := message{
+		Title:   msg.Subject,
+		Message: content,
+		Extras: map[string]any{
+			"client::display": map[string]string{"contentType": "text/markdown"},
+		},
+	}
+	// Gotify treats a missing priority as the default priority of the app,
+	// so only send one if the destination sets it.
+	if msg.Priority != 0 {
+		m.Priority = &msg.Priority
+	}
+	if msg.Click != "" {
+		m.Extras["client::notification"] = map[string]any{
+			"click": map[string]string{"url": msg.Click},
+		}
+	}
+	if len(msg.Tags) > 0 {
+		// Gotify has no tags, so they are kept in extras for plugins and
+		// clients that want them.
+		m.Extras["ruf::message"] = map[string]any{"tags": msg.Tags}
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.server+"/message", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to push to gotify: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error       string `json:"error"`
+			Description string `json:"errorDescription"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return "", fmt.Errorf("failed to push to gotify: status code %d: %s: %s", resp.StatusCode, apiErr.Error, apiErr.Description)
+	}
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode gotify message: %w", err)
+	}
+	return strconv.FormatInt(created.ID, 10), nil
+}
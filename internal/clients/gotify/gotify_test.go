@@ -0,0 +1,79 @@
+package gotify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeGotify(t *testing.T) (*httptest.Server, *map[string]any) {
+	t.Helper()
+
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/message", r.URL.Path)
+		if r.Header.Get("X-Gotify-Key") != "app-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Unauthorized", "errorCode": 401, "errorDescription": "you need to provide a valid access token or user credentials to access this api"}`))
+			return
+		}
+		received = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"id": 25, "appid": 5, "message": "ok"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func TestClient_Send(t *testing.T) {
+	server, received := newFakeGotify(t)
+	c := NewClient(server.URL+"/", map[string]string{"On-Call": "app-token"})
+
+	id, err := c.Send(&clients.Message{
+		To:       "on-call",
+		Author:   "author@example.com",
+		Subject:  "Deploy freeze",
+		Content:  "No deploys **today**.",
+		Priority: 8,
+		Tags:     []string{"warning"},
+		Click:    "https://example.com/freeze",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "25", id)
+
+	assert.Equal(t, map[string]any{
+		"title":    "Deploy freeze",
+		"message":  "No deploys **today**.\n\n---\nThx: author@example.com",
+		"priority": float64(8),
+		"extras": map[string]any{
+			"client::display":      map[string]any{"contentType": "text/markdown"},
+			"client::notification": map[string]any{"click": map[string]any{"url": "https://example.com/freeze"}},
+			"ruf::message":         map[string]any{"tags": []any{"warning"}},
+		},
+	}, *received)
+
+	_, err = c.Send(&clients.Message{To: "on-call", Content: "Hello, world!"})
+	require.NoError(t, err)
+	assert.NotContains(t, *received, "priority")
+	assert.NotContains(t, (*received)["extras"], "client::notification")
+}
+
+func TestClient_SendErrors(t *testing.T) {
+	server, _ := newFakeGotify(t)
+	c := NewClient(server.URL, map[string]string{"on-call": "wrong-token"})
+
+	_, err := c.Send(&clients.Message{To: "on-call", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 401: Unauthorized")
+
+	_, err = c.Send(&clients.Message{To: "unknown", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "gotify app 'unknown' not found")
+
+	c = NewClient("", map[string]string{"on-call": "app-token"})
+	_, err = c.Send(&clients.Message{To: "on-call", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "no gotify server configured")
+}
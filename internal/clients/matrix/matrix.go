@@ -0,0 +1,174 @@
+package matrix
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// Client sends messages to Matrix rooms through the client-server API.
+type Client struct {
+	httpClient  *http.Client
+	homeserver  string
+	accessToken string
+
+	mu    sync.Mutex
+	rooms map[string]string
+}
+
+// NewClient creates a new Matrix client for the given homeserver URL.
+func NewClient(homeserver, accessToken string) *Client {
+	return &Client{
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		homeserver:  strings.TrimSuffix(homeserver, "/"),
+		accessToken: accessToken,
+		rooms:       make(map[string]string),
+	}
+}
+
+// Send sends the message as an m.room.message event with both a plain and an
+// HTML body, and returns the ID of the event.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	roomID, err := c.GetRoomID(msg.To)
+	if err != nil {
+		return "", fmt.Errorf("failed to get room id: %w", err)
+	}
+
+	plain := msg.Content
+	formatted := strings.ReplaceAll(html.EscapeString(msg.Content), "\n", "<br>")
+	if msg.Subject != "" {
+		plain = fmt.Sprintf("%s\n%s", msg.Subject, plain)
+		formatted = fmt.Sprintf("<strong>%s</strong><br>%s", html.EscapeString(msg.Subject), formatted)
+	}
+	if msg.Author != "" {
+		plain = fmt.Sprintf("%s\n\n---\nThx: %s", plain, msg.Author)
+		formatted = fmt.Sprintf("%s<hr>Thx: %s", formatted, html.EscapeString(msg.Author))
+	}
+
+	content := map[string]any{
+		"msgtype":        "m.text",
+		"body":           plain,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted,
+	}
+	if msg.ReplyTo != "" {
+		content["m.relates_to"] = map[string]any{
+			"rel_type": "m.thread",
+			"event_id": msg.ReplyTo,
+		}
+	}
+
+	// The transaction ID is derived from the call, so that a retried send is
+	// not posted twice.
+	txnID := transactionID(msg.CampaignID, msg.CallID, msg.To)
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%s", url.PathEscape(roomID), txnID)
+
+	var sent struct {
+		EventID string `json:"event_id"`
+	}
+	if err := c.do(http.MethodPut, path, content, &sent); err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+	return sent.EventID, nil
+}
+
+// Delete redacts an event that was sent to a room.
+func (c *Client) Delete(to, eventID string) error {
+	roomID, err := c.GetRoomID(to)
+	if err != nil {
+		return fmt.Errorf("failed to get room id: %w", err)
+	}
+
+	txnID := transactionID("redact", eventID)
+	path := fmt.Sprintf("/rooms/%s/redact/%s/%s", url.PathEscape(roomID), url.PathEscape(eventID), txnID)
+	if err := c.do(http.MethodPut, path, map[string]string{}, nil); err != nil {
+		return fmt.Errorf("failed to redact event: %w", err)
+	}
+	return nil
+}
+
+// GetRoomID resolves a destination address, which is either a room ID such as
+// "!abc:example.com" or a room alias such as "#room:example.com", to a room ID.
+func (c *Client) GetRoomID(to string) (string, error) {
+	if !strings.HasPrefix(to, "#") {
+		return to, nil
+	}
+
+	c.mu.Lock()
+	id, ok := c.rooms[to]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var room struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := c.do(http.MethodGet, "/directory/room/"+url.PathEscape(to), nil, &room); err != nil {
+		return "", fmt.Errorf("room '%s' not found: %w", to, err)
+	}
+
+	c.mu.Lock()
+	c.rooms[to] = room.RoomID
+	c.mu.Unlock()
+	return room.RoomID, nil
+}
+
+// do calls a client-server API endpoint, encoding in as the request body and
+// decoding the response body into out, if they are not nil.
+func (c *Client) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.homeserver+"/_matrix/client/v3"+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return fmt.Errorf("status code %d: %s: %s", resp.StatusCode, apiErr.ErrCode, apiErr.Error)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+func transactionID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return fmt.Sprintf("ruf-%x", sum[:16])
+}
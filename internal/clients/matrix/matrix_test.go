@@ -0,0 +1,136 @@
+package matrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHomeserver is a fake of the client-server API endpoints used by the client.
+type fakeHomeserver struct {
+	mu       sync.Mutex
+	events   map[string]map[string]any
+	txns     map[string]string
+	redacted []string
+}
+
+func newFakeHomeserver(t *testing.T) (*httptest.Server, *fakeHomeserver) {
+	t.Helper()
+
+	f := &fakeHomeserver{
+		events: make(map[string]map[string]any),
+		txns:   make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_matrix/client/v3/directory/room/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("alias") != "#announcements:example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errcode": "M_NOT_FOUND", "error": "Room alias not found"}`))
+			return
+		}
+		w.Write([]byte(`{"room_id": "!room:example.com", "servers": ["example.com"]}`))
+	})
+	mux.HandleFunc("PUT /_matrix/client/v3/rooms/{room}/send/m.room.message/{txn}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "!room:example.com", r.PathValue("room"))
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		// Transactions are idempotent, as they are on a real homeserver.
+		if eventID, ok := f.txns[r.PathValue("txn")]; ok {
+			json.NewEncoder(w).Encode(map[string]string{"event_id": eventID})
+			return
+		}
+
+		var content map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&content))
+		eventID := fmt.Sprintf("$event-%d", len(f.events)+1)
+		f.events[eventID] = content
+		f.txns[r.PathValue("txn")] = eventID
+		json.NewEncoder(w).Encode(map[string]string{"event_id": eventID})
+	})
+	mux.HandleFunc("PUT /_matrix/client/v3/rooms/{room}/redact/{event}/{txn}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.redacted = append(f.redacted, r.PathValue("event"))
+		w.Write([]byte(`{"event_id": "$redaction"}`))
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode": "M_UNKNOWN_TOKEN", "error": "Invalid access token passed."}`))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, f
+}
+
+func TestClient_SendAndRedact(t *testing.T) {
+	server, f := newFakeHomeserver(t)
+	c := NewClient(server.URL+"/", "access-token")
+
+	msg := &clients.Message{
+		CampaignID: "campaign",
+		CallID:     "call-1",
+		To:         "#announcements:example.com",
+		Author:     "author@example.com",
+		Subject:    "Hello!",
+		Content:    "Hello, <world>!\nBye.",
+	}
+	eventID, err := c.Send(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "$event-1", eventID)
+
+	// Sending the same call again reuses the transaction.
+	again, err := c.Send(msg)
+	require.NoError(t, err)
+	assert.Equal(t, eventID, again)
+
+	reply, err := c.Send(&clients.Message{
+		CampaignID: "campaign",
+		CallID:     "call-2",
+		To:         "!room:example.com",
+		Content:    "Reminder!",
+		ReplyTo:    eventID,
+	})
+	require.NoError(t, err)
+
+	f.mu.Lock()
+	content, replyContent := f.events[eventID], f.events[reply]
+	f.mu.Unlock()
+
+	assert.Equal(t, "m.text", content["msgtype"])
+	assert.Equal(t, "Hello!\nHello, <world>!\nBye.\n\n---\nThx: author@example.com", content["body"])
+	assert.Equal(t, "org.matrix.custom.html", content["format"])
+	assert.Equal(t, "<strong>Hello!</strong><br>Hello, &lt;world&gt;!<br>Bye.<hr>Thx: author@example.com", content["formatted_body"])
+	assert.Nil(t, content["m.relates_to"])
+	assert.Equal(t, map[string]any{"rel_type": "m.thread", "event_id": eventID}, replyContent["m.relates_to"])
+
+	require.NoError(t, c.Delete("#announcements:example.com", eventID))
+	f.mu.Lock()
+	assert.Equal(t, []string{eventID}, f.redacted)
+	f.mu.Unlock()
+}
+
+func TestClient_Errors(t *testing.T) {
+	server, _ := newFakeHomeserver(t)
+
+	c := NewClient(server.URL, "access-token")
+	_, err := c.Send(&clients.Message{To: "#missing:example.com", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "M_NOT_FOUND")
+
+	c = NewClient(server.URL, "wrong-token")
+	_, err = c.Send(&clients.Message{To: "!room:example.com", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 401: M_UNKNOWN_TOKEN")
+}
@@ -0,0 +1,172 @@
+package mattermost
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// Client posts messages to Mattermost channels through the REST API, as a
+// bot or personal access token.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+	team       string
+
+	mu       sync.Mutex
+	channels map[string]string
+}
+
+// NewClient creates a new Mattermost client for the server at baseURL. Team
+// is the name of the team used to resolve channel names that don't specify one.
+func NewClient(baseURL, token, team string) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		team:       team,
+		channels:   make(map[string]string),
+	}
+}
+
+// Send posts the message as markdown, replying in the thread of msg.ReplyTo if
+// it is set, and returns the ID of the post.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	channelID, err := c.GetChannelID(msg.To)
+	if err != nil {
+		return "", fmt.Errorf("failed to get channel id: %w", err)
+	}
+
+	message := msg.Content
+	if msg.Subject != "" {
+		message = fmt.Sprintf("**%s**\n%s", msg.Subject, msg.Content)
+	}
+	if msg.Author != "" {
+		var user struct {
+			Username string `json:"username"`
+		}
+		err := c.do(http.MethodGet, "/users/email/"+url.PathEscape(msg.Author), nil, &user)
+		if err != nil {
+			// If the user is not found, fall back to the email address.
+			message = fmt.Sprintf("%s\n\n---\nThx: %s", message, msg.Author)
+		} else {
+			message = fmt.Sprintf("%s\n\n---\nThx: @%s", message, user.Username)
+		}
+	}
+
+	post := map[string]string{
+		"channel_id": channelID,
+		"message":    message,
+	}
+	if msg.ReplyTo != "" {
+		post["root_id"] = msg.ReplyTo
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(http.MethodPost, "/posts", post, &created); err != nil {
+		return "", fmt.Errorf("failed to create post: %w", err)
+	}
+	return created.ID, nil
+}
+
+// Delete deletes a post.
+func (c *Client) Delete(to, postID string) error {
+	if err := c.do(http.MethodDelete, "/posts/"+url.PathEscape(postID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete post: %w", err)
+	}
+	return nil
+}
+
+// GetChannelID resolves a destination address to a channel ID. Addresses are
+// either a channel ID, a channel name prefixed with "~" in the configured
+// team, or a team qualified channel name such as "team/~channel".
+func (c *Client) GetChannelID(to string) (string, error) {
+	team, channel, qualified := strings.Cut(to, "/")
+	if !qualified {
+		team, channel = c.team, to
+	}
+	if !strings.HasPrefix(channel, "~") {
+		if qualified {
+			return "", fmt.Errorf("invalid channel '%s': expected team/~channel", to)
+		}
+		return to, nil
+	}
+	if team == "" {
+		return "", fmt.Errorf("no team configured to resolve channel '%s'", to)
+	}
+
+	key := team + "/" + channel
+	c.mu.Lock()
+	id, ok := c.channels[key]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var ch struct {
+		ID string `json:"id"`
+	}
+	path := fmt.Sprintf("/teams/name/%s/channels/name/%s", url.PathEscape(team), url.PathEscape(strings.TrimPrefix(channel, "~")))
+	if err := c.do(http.MethodGet, path, nil, &ch); err != nil {
+		return "", fmt.Errorf("channel '%s' not found: %w", to, err)
+	}
+
+	c.mu.Lock()
+	c.channels[key] = ch.ID
+	c.mu.Unlock()
+	return ch.ID, nil
+}
+
+// do calls an API v4 endpoint, encoding in as the request body and decoding
+// the response body into out, if they are not nil.
+func (c *Client) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+"/api/v4"+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, apiErr.Message)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
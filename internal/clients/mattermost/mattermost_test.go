@@ -0,0 +1,158 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMattermost is a fake of the Mattermost API v4 endpoints used by the client.
+type fakeMattermost struct {
+	mu            sync.Mutex
+	posts         map[string]map[string]string
+	deleted       []string
+	channelLookup int
+}
+
+func newFakeMattermost(t *testing.T) (*httptest.Server, *fakeMattermost) {
+	t.Helper()
+
+	f := &fakeMattermost{posts: make(map[string]map[string]string)}
+	channels := map[string]string{
+		"engineering/general": "channel-general",
+		"marketing/launches":  "channel-launches",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/teams/name/{team}/channels/name/{channel}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.channelLookup++
+		f.mu.Unlock()
+		id, ok := channels[r.PathValue("team")+"/"+r.PathValue("channel")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Unable to find the existing channel."}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	})
+	mux.HandleFunc("GET /api/v4/users/email/{email}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("email") != "author@example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": "user-1", "username": "author"})
+	})
+	mux.HandleFunc("POST /api/v4/posts", func(w http.ResponseWriter, r *http.Request) {
+		var post map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&post))
+		f.mu.Lock()
+		post["id"] = fmt.Sprintf("post-%d", len(f.posts)+1)
+		f.posts[post["id"]] = post
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(post)
+	})
+	mux.HandleFunc("DELETE /api/v4/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.posts[r.PathValue("id")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.deleted = append(f.deleted, r.PathValue("id"))
+		w.Write([]byte(`{"status": "OK"}`))
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer bot-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Invalid or expired session, please login again."}`))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, f
+}
+
+func TestClient_SendThreadAndDelete(t *testing.T) {
+	server, f := newFakeMattermost(t)
+	c := NewClient(server.URL+"/", "bot-token", "engineering")
+
+	rootID, err := c.Send(&clients.Message{
+		To:      "~general",
+		Author:  "author@example.com",
+		Subject: "Hello!",
+		Content: "Hello, world!",
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, rootID)
+
+	replyID, err := c.Send(&clients.Message{
+		To:      "~general",
+		Author:  "unknown@example.com",
+		Content: "Reminder!",
+		ReplyTo: rootID,
+	})
+	require.NoError(t, err)
+
+	f.mu.Lock()
+	root, reply := f.posts[rootID], f.posts[replyID]
+	lookups := f.channelLookup
+	f.mu.Unlock()
+
+	assert.Equal(t, "channel-general", root["channel_id"])
+	assert.Equal(t, "**Hello!**\nHello, world!\n\n---\nThx: @author", root["message"])
+	assert.Empty(t, root["root_id"])
+	assert.Equal(t, "Reminder!\n\n---\nThx: unknown@example.com", reply["message"])
+	assert.Equal(t, rootID, reply["root_id"])
+	// Channel IDs are cached after the first lookup.
+	assert.Equal(t, 1, lookups)
+
+	require.NoError(t, c.Delete("~general", rootID))
+	f.mu.Lock()
+	assert.Equal(t, []string{rootID}, f.deleted)
+	f.mu.Unlock()
+}
+
+func TestClient_GetChannelID(t *testing.T) {
+	server, _ := newFakeMattermost(t)
+	c := NewClient(server.URL, "bot-token", "")
+
+	id, err := c.GetChannelID("marketing/~launches")
+	assert.NoError(t, err)
+	assert.Equal(t, "channel-launches", id)
+
+	id, err = c.GetChannelID("4xp9fdt77pncbef59f4k1qe83o")
+	assert.NoError(t, err)
+	assert.Equal(t, "4xp9fdt77pncbef59f4k1qe83o", id)
+
+	_, err = c.GetChannelID("~general")
+	assert.ErrorContains(t, err, "no team configured")
+
+	_, err = c.GetChannelID("marketing/launches")
+	assert.ErrorContains(t, err, "expected team/~channel")
+
+	_, err = c.GetChannelID("marketing/~missing")
+	assert.ErrorContains(t, err, "Unable to find the existing channel.")
+}
+
+func TestClient_Unauthorized(t *testing.T) {
+	server, _ := newFakeMattermost(t)
+	c := NewClient(server.URL, "wrong-token", "engineering")
+
+	_, err := c.Send(&clients.Message{To: "channel-general", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 401")
+
+	err = c.Delete("channel-general", "post-1")
+	assert.ErrorContains(t, err, "status code 401")
+}
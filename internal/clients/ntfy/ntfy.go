@@ -0,0 +1,100 @@
+package ntfy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// DefaultServer is the URL of the public ntfy server.
+const DefaultServer = "https://ntfy.sh"
+
+// Client publishes messages to ntfy topics.
+type Client struct {
+	httpClient *http.Client
+	server     string
+	token      string
+}
+
+// NewClient creates a new ntfy client for the server, which defaults to
+// DefaultServer if it is empty. The token is an access token, which is only
+// needed for topics that are protected.
+func NewClient(server, token string) *Client {
+	if server == "" {
+		server = DefaultServer
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		server:     strings.TrimSuffix(server, "/"),
+		token:      token,
+	}
+}
+
+type publish struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Click    string   `json:"click,omitempty"`
+	Markdown bool     `json:"markdown"`
+}
+
+// Send publishes the message to the topic in msg.To, and returns the ID of the
+// message.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	message := msg.Content
+	if msg.Author != "" {
+		message = fmt.Sprintf("%s\n\n---\nThx: %s", message, msg.Author)
+	}
+
+	body, err := json.Marshal(publish{
+		Topic:    msg.To,
+		Title:    msg.Subject,
+		Message:  message,
+		Priority: msg.Priority,
+		Tags:     msg.Tags,
+		Click:    msg.Click,
+		Markdown: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.server+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish to ntfy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return "", fmt.Errorf("failed to publish to ntfy: status code %d: %s", resp.StatusCode, apiErr.Error)
+	}
+
+	var published struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&published); err != nil {
+		return "", fmt.Errorf("failed to decode ntfy message: %w", err)
+	}
+	return published.ID, nil
+}
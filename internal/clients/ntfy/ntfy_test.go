@@ -0,0 +1,82 @@
+package ntfy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Send(t *testing.T) {
+	var auth string
+	var received publish
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/", r.URL.Path)
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"id": "sPs71M8A2T", "time": 1735808400, "event": "message", "topic": "on-call"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL+"/", "tk_token")
+
+	id, err := c.Send(&clients.Message{
+		To:       "on-call",
+		Author:   "author@example.com",
+		Subject:  "Deploy freeze",
+		Content:  "No deploys **today**.",
+		Priority: 4,
+		Tags:     []string{"warning", "rotating_light"},
+		Click:    "https://example.com/freeze",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sPs71M8A2T", id)
+
+	assert.Equal(t, "Bearer tk_token", auth)
+	assert.Equal(t, publish{
+		Topic:    "on-call",
+		Title:    "Deploy freeze",
+		Message:  "No deploys **today**.\n\n---\nThx: author@example.com",
+		Priority: 4,
+		Tags:     []string{"warning", "rotating_light"},
+		Click:    "https://example.com/freeze",
+		Markdown: true,
+	}, received)
+}
+
+func TestClient_SendWithoutToken(t *testing.T) {
+	var auth []string
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Values("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"id": "abc"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "")
+	_, err := c.Send(&clients.Message{To: "alerts", Content: "Hello, world!"})
+	require.NoError(t, err)
+
+	assert.Empty(t, auth)
+	assert.NotContains(t, received, "priority")
+	assert.NotContains(t, received, "tags")
+	assert.NotContains(t, received, "click")
+}
+
+func TestClient_SendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code": 40301, "http": 403, "error": "forbidden"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "wrong")
+	_, err := c.Send(&clients.Message{To: "on-call", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 403: forbidden")
+}
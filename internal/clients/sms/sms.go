@@ -0,0 +1,200 @@
+package sms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// DefaultAPIURL is the base URL of the Twilio REST API.
+const DefaultAPIURL = "https://api.twilio.com"
+
+// DefaultMaxSegments is the default number of segments a message can be sent
+// in before it is shortened.
+const DefaultMaxSegments = 3
+
+// E164 matches phone numbers in E.164 format, such as "+14155550100".
+var E164 = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// gsm7 are the characters in the GSM 03.38 basic character set, which can be
+// sent in 7 bits each. gsm7Extended are the characters in its extension
+// table, which take two.
+const (
+	gsm7 = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extended = "\f^{}\\[~]|€"
+)
+
+// Config is the configuration of an SMS client.
+type Config struct {
+	// APIURL is the base URL of a Twilio compatible API. It defaults to
+	// DefaultAPIURL.
+	APIURL     string
+	AccountSID string
+	AuthToken  string
+	// From is the number to send messages from, or the SID of a messaging
+	// service.
+	From string
+	// MaxSegments is the number of segments a message is shortened to fit in.
+	// It defaults to DefaultMaxSegments.
+	MaxSegments int
+}
+
+// Client sends text messages through a Twilio compatible REST API.
+type Client struct {
+	httpClient *http.Client
+	cfg        Config
+}
+
+// NewClient creates a new SMS client.
+func NewClient(cfg Config) *Client {
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultAPIURL
+	}
+	cfg.APIURL = strings.TrimSuffix(cfg.APIURL, "/")
+	if cfg.MaxSegments <= 0 {
+		cfg.MaxSegments = DefaultMaxSegments
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		cfg:        cfg,
+	}
+}
+
+// Send sends the subject and content of the message as a single plain text
+// message to the number in msg.To, and returns the SID of the message.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	if !E164.MatchString(msg.To) {
+		return "", fmt.Errorf("invalid phone number '%s': must be in E.164 format, such as +14155550100", msg.To)
+	}
+	if c.cfg.AccountSID == "" || c.cfg.From == "" {
+		return "", fmt.Errorf("sms account sid and from number are required")
+	}
+
+	form := url.Values{
+		"To":   {msg.To},
+		"Body": {Shorten(Text(msg.Subject, msg.Content), c.cfg.MaxSegments)},
+	}
+	if strings.HasPrefix(c.cfg.From, "MG") {
+		form.Set("MessagingServiceSid", c.cfg.From)
+	} else {
+		form.Set("From", c.cfg.From)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", c.cfg.APIURL, url.PathEscape(c.cfg.AccountSID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.cfg.AccountSID, c.cfg.AuthToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send sms: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return "", fmt.Errorf("failed to send sms: status code %d: %d: %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+
+	var created struct {
+		SID string `json:"sid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode sms message: %w", err)
+	}
+	return created.SID, nil
+}
+
+// Text collapses a subject and content into a single line of plain text.
+func Text(subject, content string) string {
+	text := strings.Join(strings.Fields(content), " ")
+	if subject = strings.Join(strings.Fields(subject), " "); subject != "" {
+		text = subject + ": " + text
+	}
+	return text
+}
+
+// Segments returns the number of SMS segments text is sent in. Text that only
+// uses the GSM 7-bit alphabet fits 160 characters in one segment, or 153 per
+// segment when it is split. Any other text is sent as UCS-2, which fits 70
+// characters in one segment, or 67 per segment when it is split.
+func Segments(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	units, ok := gsm7Units(text)
+	single, multi := 160, 153
+	if !ok {
+		units, single, multi = len(utf16.Encode([]rune(text))), 70, 67
+	}
+
+	if units <= single {
+		return 1
+	}
+	return (units + multi - 1) / multi
+}
+
+// gsm7Units returns the number of septets text takes in the GSM 7-bit
+// alphabet, or false if it has characters that are not in the alphabet.
+func gsm7Units(text string) (int, bool) {
+	units := 0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsm7, r):
+			units++
+		case strings.ContainsRune(gsm7Extended, r):
+			units += 2
+		default:
+			return 0, false
+		}
+	}
+	return units, true
+}
+
+// Shorten shortens text at a word boundary, if it needs to be, so that it is
+// sent in at most maxSegments segments.
+func Shorten(text string, maxSegments int) string {
+	if Segments(text) <= maxSegments {
+		return text
+	}
+
+	// The ellipsis is three dots, as "…" is not in the GSM alphabet.
+	const ellipsis = "..."
+	var shortened string
+	for _, word := range strings.Fields(text) {
+		next := word
+		if shortened != "" {
+			next = shortened + " " + word
+		}
+		if Segments(next+ellipsis) > maxSegments {
+			break
+		}
+		shortened = next
+	}
+	if shortened == "" {
+		// The first word is too long by itself, so cut it.
+		runes := []rune(text)
+		for len(runes) > 0 && Segments(string(runes)+ellipsis) > maxSegments {
+			runes = runes[:len(runes)-1]
+		}
+		shortened = string(runes)
+	}
+	return shortened + ellipsis
+}
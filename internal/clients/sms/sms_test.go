@@ -0,0 +1,112 @@
+package sms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Send(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "AC123" || pass != "auth-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": 20003, "message": "Authenticate", "status": 401}`))
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "SM0123456789abcdef", "status": "queued"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(Config{
+		APIURL:     server.URL + "/",
+		AccountSID: "AC123",
+		AuthToken:  "auth-token",
+		From:       "+14155550100",
+	})
+
+	sid, err := c.Send(&clients.Message{
+		To:      "+442071838750",
+		Author:  "author@example.com",
+		Subject: "Office closed",
+		Content: "The office is closed today.\n\nPlease work from home.",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SM0123456789abcdef", sid)
+	assert.Equal(t, url.Values{
+		"To":   {"+442071838750"},
+		"From": {"+14155550100"},
+		"Body": {"Office closed: The office is closed today. Please work from home."},
+	}, form)
+
+	c = NewClient(Config{APIURL: server.URL, AccountSID: "AC123", AuthToken: "auth-token", From: "MG123"})
+	_, err = c.Send(&clients.Message{To: "+442071838750", Content: "Hello!"})
+	require.NoError(t, err)
+	assert.Equal(t, "MG123", form.Get("MessagingServiceSid"))
+	assert.Empty(t, form.Get("From"))
+
+	c = NewClient(Config{APIURL: server.URL, AccountSID: "AC123", AuthToken: "wrong", From: "+14155550100"})
+	_, err = c.Send(&clients.Message{To: "+442071838750", Content: "Hello!"})
+	assert.ErrorContains(t, err, "status code 401: 20003: Authenticate")
+}
+
+func TestClient_SendInvalid(t *testing.T) {
+	c := NewClient(Config{AccountSID: "AC123", From: "+14155550100"})
+	_, err := c.Send(&clients.Message{To: "020 7183 8750", Content: "Hello!"})
+	assert.ErrorContains(t, err, "must be in E.164 format")
+
+	c = NewClient(Config{})
+	_, err = c.Send(&clients.Message{To: "+442071838750", Content: "Hello!"})
+	assert.ErrorContains(t, err, "account sid and from number are required")
+}
+
+func TestSegments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{"empty", "", 0},
+		{"single gsm", strings.Repeat("a", 160), 1},
+		{"two gsm", strings.Repeat("a", 161), 2},
+		{"three gsm", strings.Repeat("a", 307), 3},
+		{"extended gsm", strings.Repeat("€", 80), 1},
+		{"extended gsm split", strings.Repeat("€", 81), 2},
+		{"single ucs-2", strings.Repeat("ł", 70), 1},
+		{"two ucs-2", strings.Repeat("ł", 71), 2},
+		{"surrogate pairs", strings.Repeat("😀", 35), 1},
+		{"mixed", strings.Repeat("a", 69) + "ł", 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Segments(tc.text))
+		})
+	}
+}
+
+func TestShorten(t *testing.T) {
+	short := "Office closed: see you tomorrow."
+	assert.Equal(t, short, Shorten(short, 1))
+
+	long := strings.Repeat("word ", 100)
+	shortened := Shorten(long, 2)
+	assert.Equal(t, 2, Segments(shortened))
+	assert.True(t, strings.HasSuffix(shortened, "word..."))
+	assert.Greater(t, len(shortened), 290)
+
+	unbroken := strings.Repeat("ł", 200)
+	shortened = Shorten(unbroken, 1)
+	assert.Equal(t, 1, Segments(shortened))
+	assert.Equal(t, strings.Repeat("ł", 67)+"...", shortened)
+}
@@ -0,0 +1,97 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// Client posts messages to Microsoft Teams incoming webhooks or Workflows.
+type Client struct {
+	httpClient *http.Client
+	webhooks   clients.Webhooks
+}
+
+// NewClient creates a new Teams client. Webhooks maps aliases that can be
+// used as destination addresses to webhook URLs.
+func NewClient(webhooks map[string]string) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		webhooks:   clients.NewWebhooks(webhooks),
+	}
+}
+
+// Send posts the message to the webhook as an Adaptive Card. Teams does not
+// return a reference to the posted message.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	url, err := c.webhooks.Resolve(msg.To)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve teams webhook: %w", err)
+	}
+
+	payload, err := json.Marshal(card(msg))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal card: %w", err)
+	}
+
+	resp, err := c.httpClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to post to teams webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to post to teams webhook: status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return "", nil
+}
+
+// card builds the webhook payload for a message.
+func card(msg *clients.Message) map[string]any {
+	var body []map[string]any
+	if msg.Subject != "" {
+		body = append(body, map[string]any{
+			"type":   "TextBlock",
+			"text":   msg.Subject,
+			"size":   "Medium",
+			"weight": "Bolder",
+			"wrap":   true,
+		})
+	}
+	body = append(body, map[string]any{
+		"type": "TextBlock",
+		"text": msg.Content,
+		"wrap": true,
+	})
+	if msg.Author != "" {
+		body = append(body, map[string]any{
+			"type":     "TextBlock",
+			"text":     "Thx: " + msg.Author,
+			"isSubtle": true,
+			"wrap":     true,
+		})
+	}
+
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+}
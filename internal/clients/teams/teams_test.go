@@ -0,0 +1,79 @@
+package teams
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Send(t *testing.T) {
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		// Workflows webhooks accept the message without a body.
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	c := NewClient(map[string]string{"Engineering": server.URL})
+
+	ref, err := c.Send(&clients.Message{
+		To:      "engineering",
+		Author:  "author@example.com",
+		Subject: "Hello!",
+		Content: "Hello, world!",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, ref)
+
+	attachments := received["attachments"].([]any)
+	require.Len(t, attachments, 1)
+	attachment := attachments[0].(map[string]any)
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", attachment["contentType"])
+
+	content := attachment["content"].(map[string]any)
+	assert.Equal(t, "AdaptiveCard", content["type"])
+	body := content["body"].([]any)
+	require.Len(t, body, 3)
+	assert.Equal(t, "Hello!", body[0].(map[string]any)["text"])
+	assert.Equal(t, "Bolder", body[0].(map[string]any)["weight"])
+	assert.Equal(t, "Hello, world!", body[1].(map[string]any)["text"])
+	assert.Equal(t, "Thx: author@example.com", body[2].(map[string]any)["text"])
+}
+
+func TestClient_SendToURL(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("1"))
+	}))
+	defer server.Close()
+
+	c := NewClient(nil)
+	_, err := c.Send(&clients.Message{To: server.URL, Content: "Hello, world!"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestClient_SendErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Webhook message delivery failed", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := NewClient(map[string]string{"broken": server.URL})
+
+	_, err := c.Send(&clients.Message{To: "broken", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 400")
+	assert.ErrorContains(t, err, "Webhook message delivery failed")
+
+	_, err = c.Send(&clients.Message{To: "unknown", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "webhook 'unknown' not found")
+}
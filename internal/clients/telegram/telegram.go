@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+)
+
+// DefaultAPIURL is the base URL of the Telegram Bot API.
+const DefaultAPIURL = "https://api.telegram.org"
+
+// The parse modes that messages can be formatted with.
+const (
+	ParseModeHTML       = "HTML"
+	ParseModeMarkdownV2 = "MarkdownV2"
+)
+
+// maxLength is the maximum length of a message, after entities are parsed, in
+// UTF-16 code units.
+const maxLength = 4096
+
+// Client sends messages to Telegram chats through the Bot API.
+type Client struct {
+	httpClient *http.Client
+	apiURL     string
+	token      string
+	parseMode  string
+}
+
+// NewClient creates a new Telegram client for a bot token. The apiURL is the
+// base URL of the Bot API, which defaults to DefaultAPIURL if it is empty, and
+// parseMode is one of ParseModeHTML (the default) or ParseModeMarkdownV2.
+func NewClient(apiURL, token, parseMode string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	if parseMode == "" {
+		parseMode = ParseModeHTML
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		token:      token,
+		parseMode:  parseMode,
+	}
+}
+
+// Send sends the message to a chat ID or @channel username with sendMessage,
+// replying to msg.ReplyTo if it is set, and returns the ID of the message.
+func (c *Client) Send(msg *clients.Message) (string, error) {
+	text, err := c.format(msg)
+	if err != nil {
+		return "", err
+	}
+
+	req := map[string]any{
+		"chat_id":    msg.To,
+		"text":       text,
+		"parse_mode": c.parseMode,
+	}
+	if msg.ReplyTo != "" {
+		id, err := strconv.ParseInt(msg.ReplyTo, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid message id '%s': %w", msg.ReplyTo, err)
+		}
+		req["reply_parameters"] = map[string]any{
+			"message_id":                  id,
+			"allow_sending_without_reply": true,
+		}
+	}
+
+	var sent struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if err := c.call("sendMessage", req, &sent); err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+	return strconv.FormatInt(sent.MessageID, 10), nil
+}
+
+// Delete deletes a message that was sent to a chat.
+func (c *Client) Delete(to, messageID string) error {
+	id, err := strconv.ParseInt(messageID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid message id '%s': %w", messageID, err)
+	}
+
+	req := map[string]any{
+		"chat_id":    to,
+		"message_id": id,
+	}
+	if err := c.call("deleteMessage", req, nil); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	return nil
+}
+
+// format renders the message in the parse mode of the client, shortening the
+// content so that the message fits in a single Telegram message.
+func (c *Client) format(msg *clients.Message) (string, error) {
+	var escape func(string) string
+	var bold func(string) string
+	switch c.parseMode {
+	case ParseModeHTML:
+		escape = html.EscapeString
+		bold = func(s string) string { return "<b>" + s + "</b>" }
+	case ParseModeMarkdownV2:
+		escape = escapeMarkdownV2
+		bold = func(s string) string { return "*" + s + "*" }
+	default:
+		return "", fmt.Errorf("unsupported parse mode '%s'", c.parseMode)
+	}
+
+	var header, footer string
+	if msg.Subject != "" {
+		header = msg.Subject + "\n"
+	}
+	if msg.Author != "" {
+		footer = "\n\n---\nThx: " + msg.Author
+	}
+	content := truncate(msg.Content, maxLength-length(header)-length(footer))
+
+	var text strings.Builder
+	if msg.Subject != "" {
+		text.WriteString(bold(escape(msg.Subject)) + "\n")
+	}
+	text.WriteString(escape(content))
+	text.WriteString(escape(footer))
+	return text.String(), nil
+}
+
+// call calls a Bot API method, decoding its result into out if it is not nil.
+func (c *Client) call(method string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.httpClient.Post(c.apiURL+"/bot"+c.token+"/"+method, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The error includes the URL, which contains the bot token.
+		return fmt.Errorf("failed to call %s: %w", method, redact(err, c.token))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode response: status code %d: %w", resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("status code %d: %s", result.ErrorCode, result.Description)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(result.Result, out); err != nil {
+			return fmt.Errorf("failed to decode result: %w", err)
+		}
+	}
+	return nil
+}
+
+// markdownV2Special are the characters that must be escaped in MarkdownV2.
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+func escapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// length returns the length of s as Telegram counts it, in UTF-16 code units.
+func length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// truncate shortens s to at most n UTF-16 code units.
+func truncate(s string, n int) string {
+	if length(s) <= n {
+		return s
+	}
+	var b strings.Builder
+	used := 1 // The ellipsis.
+	for _, r := range s {
+		if used+utf16.RuneLen(r) > n {
+			break
+		}
+		used += utf16.RuneLen(r)
+		b.WriteRune(r)
+	}
+	return b.String() + "…"
+}
+
+func redact(err error, token string) error {
+	if token == "" {
+		return err
+	}
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<token>"))
+}
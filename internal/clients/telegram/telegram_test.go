@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeBotAPI returns a fake Bot API that records the requests it receives.
+func newFakeBotAPI(t *testing.T) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+
+	var requests []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /botbot-token/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		if req["chat_id"] == "@missing" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ok": true, "result": {"message_id": 42, "chat": {"id": -100123}}}`))
+	})
+	mux.HandleFunc("POST /botbot-token/deleteMessage", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		w.Write([]byte(`{"ok": true, "result": true}`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"ok": false, "error_code": 401, "description": "Unauthorized"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestClient_SendAndDelete(t *testing.T) {
+	server, requests := newFakeBotAPI(t)
+	c := NewClient(server.URL+"/", "bot-token", "")
+
+	id, err := c.Send(&clients.Message{
+		To:      "@announcements",
+		Author:  "author@example.com",
+		Subject: "Hello & welcome!",
+		Content: "Hello, <world>!",
+		ReplyTo: "41",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "42", id)
+
+	require.NoError(t, c.Delete("@announcements", id))
+
+	require.Len(t, *requests, 2)
+	sent := (*requests)[0]
+	assert.Equal(t, "@announcements", sent["chat_id"])
+	assert.Equal(t, "HTML", sent["parse_mode"])
+	assert.Equal(t, "<b>Hello &amp; welcome!</b>\nHello, &lt;world&gt;!\n\n---\nThx: author@example.com", sent["text"])
+	assert.Equal(t, map[string]any{"message_id": float64(41), "allow_sending_without_reply": true}, sent["reply_parameters"])
+	assert.Equal(t, map[string]any{"chat_id": "@announcements", "message_id": float64(42)}, (*requests)[1])
+}
+
+func TestClient_SendMarkdownV2(t *testing.T) {
+	server, requests := newFakeBotAPI(t)
+	c := NewClient(server.URL, "bot-token", ParseModeMarkdownV2)
+
+	_, err := c.Send(&clients.Message{
+		To:      "-100123",
+		Author:  "author@example.com",
+		Subject: "Release v1.2!",
+		Content: "See [the notes](https://example.com) - *now*.",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, *requests, 1)
+	assert.Equal(t, "MarkdownV2", (*requests)[0]["parse_mode"])
+	assert.Equal(t,
+		"*Release v1\\.2\\!*\nSee \\[the notes\\]\\(https://example\\.com\\) \\- \\*now\\*\\.\n\n\\-\\-\\-\nThx: author@example\\.com",
+		(*requests)[0]["text"],
+	)
+	assert.Nil(t, (*requests)[0]["reply_parameters"])
+}
+
+func TestClient_SendTruncates(t *testing.T) {
+	server, requests := newFakeBotAPI(t)
+	c := NewClient(server.URL, "bot-token", ParseModeHTML)
+
+	_, err := c.Send(&clients.Message{
+		To:      "-100123",
+		Author:  "author@example.com",
+		Subject: "Subject",
+		Content: strings.Repeat("😀", 3000),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, *requests, 1)
+	text := (*requests)[0]["text"].(string)
+	visible := strings.NewReplacer("<b>", "", "</b>", "").Replace(text)
+	assert.LessOrEqual(t, length(visible), maxLength)
+	assert.Greater(t, length(visible), maxLength-2)
+	assert.Contains(t, text, "…\n\n---\nThx: author@example.com")
+}
+
+func TestClient_Errors(t *testing.T) {
+	server, _ := newFakeBotAPI(t)
+
+	c := NewClient(server.URL, "bot-token", "")
+	_, err := c.Send(&clients.Message{To: "@missing", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 400: Bad Request: chat not found")
+
+	err = c.Delete("@announcements", "not-a-number")
+	assert.ErrorContains(t, err, "invalid message id")
+
+	c = NewClient(server.URL, "wrong-token", "")
+	_, err = c.Send(&clients.Message{To: "@announcements", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "status code 401: Unauthorized")
+
+	c = NewClient(server.URL, "bot-token", "Markdown")
+	_, err = c.Send(&clients.Message{To: "@announcements", Content: "Hello, world!"})
+	assert.ErrorContains(t, err, "unsupported parse mode 'Markdown'")
+
+	c = NewClient("http://127.0.0.1:1", "secret-token", "")
+	_, err = c.Send(&clients.Message{To: "@announcements", Content: "Hello, world!"})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-token")
+}
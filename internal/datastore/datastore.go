@@ -18,7 +18,10 @@ var (
 	ErrSerializationFailed = errors.New("serialization failed")
 )
 
-var sentMessagesBucket = []byte("sent_messages")
+var (
+	sentMessagesBucket = []byte("sent_messages")
+	invitesBucket      = []byte("invites")
+)
 
 // Status represents the status of a call.
 type Status string
@@ -37,11 +40,37 @@ type SentMessage struct {
 	ID           string    `json:"id"`
 	SourceID     string    `json:"source_id"`
 	ScheduledAt  time.Time `json:"scheduled_at"`
-	Timestamp    string    `json:"timestamp,omitempty"` // Slack timestamp
+	Timestamp    string    `json:"timestamp,omitempty"`   // Slack timestamp
+	MessageID    string    `json:"message_id,omitempty"`  // Email Message-ID
+	InReplyTo    string    `json:"in_reply_to,omitempty"` // Email Message-ID of the thread root
+	Reference    string    `json:"reference,omitempty"`   // Message reference returned by other destinations
 	Destination  string    `json:"destination"`
 	Type         string    `json:"type"`
 	Status       Status    `json:"status"`
 	CampaignName string    `json:"campaign_name"`
+
+	// The rendered call, kept for destinations that are rebuilt from the
+	// sent records, such as feeds.
+	Author  string `json:"author,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// Invite represents a calendar invite that has been sent to a recipient.
+type Invite struct {
+	ID         string    `json:"id"`
+	UID        string    `json:"uid"`
+	CampaignID string    `json:"campaign_id"`
+	Recipient  string    `json:"recipient"`
+	Summary    string    `json:"summary"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time,omitempty"`
+	Sequence   int       `json:"sequence"`
+	Status     Status    `json:"status"`
+
+	// SourceURL is the URL of the source that defines the event. Invites
+	// recorded before it was added don't have one.
+	SourceURL string `json:"source_url,omitempty"`
 }
 
 // Storer is an interface that defines the methods for interacting with the datastore.
@@ -50,7 +79,11 @@ type Storer interface {
 	HasBeenSent(campaignID, callID, destType, destination string) (bool, error)
 	ListSentMessages() ([]*SentMessage, error)
 	GetSentMessage(id string) (*SentMessage, error)
+	FindSentMessage(campaignID, callID, destType, destination string) (*SentMessage, error)
 	DeleteSentMessage(id string) error
+	AddInvite(inv *Invite) error
+	GetInvite(uid, recipient string) (*Invite, error)
+	ListInvites() ([]*Invite, error)
 	Close() error
 }
 
@@ -81,9 +114,10 @@ func newStore(dbPath string) (Storer, error) {
 	}
 
 	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(sentMessagesBucket)
-		if err != nil {
-			return fmt.Errorf("%w: failed to create bucket: %w", ErrDBOperationFailed, err)
+		for _, bucket := range [][]byte{sentMessagesBucket, invitesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return fmt.Errorf("%w: failed to create bucket: %w", ErrDBOperationFailed, err)
+			}
 		}
 		return nil
 	})
@@ -197,6 +231,11 @@ func (s *Store) GetSentMessage(id string) (*SentMessage, error) {
 	return &sm, nil
 }
 
+// FindSentMessage retrieves the message sent for a call to a single destination.
+func (s *Store) FindSentMessage(campaignID, callID, destType, destination string) (*SentMessage, error) {
+	return s.GetSentMessage(s.generateID(campaignID, callID, destType, destination))
+}
+
 // DeleteSentMessage removes a sent message from the store.
 func (s *Store) DeleteSentMessage(id string) error {
 	return s.db.Update(func(tx *bbolt.Tx) error {
@@ -224,3 +263,66 @@ func (s *Store) DeleteSentMessage(id string) error {
 		return nil
 	})
 }
+
+// AddInvite adds or replaces the record of an invite sent to a recipient.
+func (s *Store) AddInvite(inv *Invite) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(invitesBucket)
+		inv.ID = strings.Join([]string{inv.UID, inv.Recipient}, "@")
+
+		buf, err := json.Marshal(inv)
+		if err != nil {
+			return fmt.Errorf("%w: failed to marshal invite: %w", ErrSerializationFailed, err)
+		}
+
+		if err := b.Put([]byte(inv.ID), buf); err != nil {
+			return fmt.Errorf("%w: failed to put invite: %w", ErrDBOperationFailed, err)
+		}
+		return nil
+	})
+}
+
+// GetInvite retrieves the invite with the given UID sent to a recipient.
+func (s *Store) GetInvite(uid, recipient string) (*Invite, error) {
+	var inv Invite
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(invitesBucket)
+		id := strings.Join([]string{uid, recipient}, "@")
+		v := b.Get([]byte(id))
+		if v == nil {
+			return fmt.Errorf("%w: invite with id '%s'", ErrNotFound, id)
+		}
+		if err := json.Unmarshal(v, &inv); err != nil {
+			return fmt.Errorf("%w: failed to unmarshal invite: %w", ErrSerializationFailed, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// ListInvites retrieves all invites from the store.
+func (s *Store) ListInvites() ([]*Invite, error) {
+	var invites []*Invite
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(invitesBucket)
+		err := b.ForEach(func(k, v []byte) error {
+			var inv Invite
+			if err := json.Unmarshal(v, &inv); err != nil {
+				return fmt.Errorf("%w: failed to unmarshal invite: %w", ErrSerializationFailed, err)
+			}
+			invites = append(invites, &inv)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%w: failed to iterate over invites: %w", ErrDBOperationFailed, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
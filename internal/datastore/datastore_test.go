@@ -38,6 +38,14 @@ func TestStore(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, hasBeenSent)
 
+	// Test FindSentMessage
+	found, err := store.FindSentMessage("campaign-1", "call-1", sm1.Type, sm1.Destination)
+	assert.NoError(t, err)
+	assert.Equal(t, sm1.ID, found.ID)
+
+	_, err = store.FindSentMessage("campaign-2", "call-2", "slack", "C1234567890")
+	assert.ErrorIs(t, err, ErrNotFound)
+
 	// Test ListSentMessages
 	sm2 := &SentMessage{
 		SourceID:    "2",
@@ -94,3 +102,43 @@ func TestListSentMessagesWithDeleted(t *testing.T) {
 	assert.Len(t, sentMessages, 1)
 	assert.Equal(t, StatusDeleted, sentMessages[0].Status)
 }
+
+func TestStore_Invites(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.db")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+
+	store, err := NewTestStore(tmpfile.Name())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// Test GetInvite with an invite that has not been sent
+	_, err = store.GetInvite("uid-1", "test@example.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Test AddInvite
+	inv := &Invite{
+		UID:        "uid-1",
+		CampaignID: "campaign-1",
+		Recipient:  "test@example.com",
+		Summary:    "Launch",
+		StartTime:  time.Now().UTC().Truncate(time.Second),
+		Status:     StatusSent,
+	}
+	err = store.AddInvite(inv)
+	assert.NoError(t, err)
+
+	got, err := store.GetInvite("uid-1", "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, inv, got)
+
+	// Test that AddInvite replaces an existing invite
+	inv.Sequence = 1
+	err = store.AddInvite(inv)
+	assert.NoError(t, err)
+
+	invites, err := store.ListInvites()
+	assert.NoError(t, err)
+	assert.Len(t, invites, 1)
+	assert.Equal(t, 1, invites[0].Sequence)
+}
@@ -9,6 +9,7 @@ import (
 // MockStore is a mock implementation of the Storer interface.
 type MockStore struct {
 	sentMessages map[string]*SentMessage
+	invites      map[string]*Invite
 	mu           sync.Mutex
 }
 
@@ -16,6 +17,7 @@ type MockStore struct {
 func NewMockStore() *MockStore {
 	return &MockStore{
 		sentMessages: make(map[string]*SentMessage),
+		invites:      make(map[string]*Invite),
 	}
 }
 
@@ -74,6 +76,11 @@ func (s *MockStore) GetSentMessage(id string) (*SentMessage, error) {
 	return sm, nil
 }
 
+// FindSentMessage retrieves the message sent for a call to a single destination from the mock store.
+func (s *MockStore) FindSentMessage(campaignID, callID, destType, destination string) (*SentMessage, error) {
+	return s.GetSentMessage(s.generateID(campaignID, callID, destType, destination))
+}
+
 // DeleteSentMessage removes a sent message from the mock store.
 func (s *MockStore) DeleteSentMessage(id string) error {
 	s.mu.Lock()
@@ -86,6 +93,38 @@ func (s *MockStore) DeleteSentMessage(id string) error {
 	return nil
 }
 
+// AddInvite adds or replaces an invite in the mock store.
+func (s *MockStore) AddInvite(inv *Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv.ID = strings.Join([]string{inv.UID, inv.Recipient}, "@")
+	s.invites[inv.ID] = inv
+	return nil
+}
+
+// GetInvite retrieves a single invite from the mock store.
+func (s *MockStore) GetInvite(uid, recipient string) (*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := strings.Join([]string{uid, recipient}, "@")
+	inv, ok := s.invites[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: invite with id '%s'", ErrNotFound, id)
+	}
+	return inv, nil
+}
+
+// ListInvites retrieves all invites from the mock store.
+func (s *MockStore) ListInvites() ([]*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var invites []*Invite
+	for _, inv := range s.invites {
+		invites = append(invites, inv)
+	}
+	return invites, nil
+}
+
 // Close is a no-op for the mock store.
 func (s *MockStore) Close() error {
 	return nil
@@ -4,43 +4,72 @@ import "time"
 
 // Destination represents a destination to send a call to.
 type Destination struct {
-	Type string   `json:"type" yaml:"type"`
-	To   []string `json:"to,omitempty" yaml:"to,omitempty"`
+	Type string   `json:"type" yaml:"type" toml:"type"`
+	To   []string `json:"to,omitempty" yaml:"to,omitempty" toml:"to,omitempty"`
+
+	// Options for push notification destinations, such as ntfy and gotify.
+	Priority int      `json:"priority,omitempty" yaml:"priority,omitempty" toml:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty" toml:"tags,omitempty"`
+	Click    string   `json:"click,omitempty" yaml:"click,omitempty" toml:"click,omitempty"`
 }
 
 // Trigger represents a scheduling mechanism for a call.
 type Trigger struct {
-	ScheduledAt time.Time `json:"scheduled_at,omitempty" yaml:"scheduled_at,omitempty"`
-	Cron        string    `json:"cron,omitempty" yaml:"cron,omitempty"`
-	Recurring   bool      `json:"recurring,omitempty" yaml:"recurring,omitempty"`
-	Delta       string    `json:"delta,omitempty" yaml:"delta,omitempty"`
-	Sequence    string    `json:"sequence,omitempty" yaml:"sequence,omitempty"`
+	ScheduledAt time.Time `json:"scheduled_at,omitempty" yaml:"scheduled_at,omitempty" toml:"scheduled_at,omitempty"`
+	Cron        string    `json:"cron,omitempty" yaml:"cron,omitempty" toml:"cron,omitempty"`
+	Recurring   bool      `json:"recurring,omitempty" yaml:"recurring,omitempty" toml:"recurring,omitempty"`
+	Delta       string    `json:"delta,omitempty" yaml:"delta,omitempty" toml:"delta,omitempty"`
+	Sequence    string    `json:"sequence,omitempty" yaml:"sequence,omitempty" toml:"sequence,omitempty"`
 }
 
 // Call represents a message to be sent to a destination.
 type Call struct {
-	ID           string        `json:"id" yaml:"id"`
-	Author       string        `json:"author,omitempty" yaml:"author,omitempty"`
-	Subject      string        `json:"subject,omitempty" yaml:"subject,omitempty"`
-	Content      string        `json:"content" yaml:"content"`
-	Destinations []Destination `json:"destinations" yaml:"destinations"`
-	Triggers     []Trigger     `json:"triggers" yaml:"triggers"`
+	ID           string        `json:"id" yaml:"id" toml:"id"`
+	Author       string        `json:"author,omitempty" yaml:"author,omitempty" toml:"author,omitempty"`
+	Subject      string        `json:"subject,omitempty" yaml:"subject,omitempty" toml:"subject,omitempty"`
+	Content      string        `json:"content" yaml:"content" toml:"content"`
+	Destinations []Destination `json:"destinations" yaml:"destinations" toml:"destinations"`
+	Triggers     []Trigger     `json:"triggers" yaml:"triggers" toml:"triggers"`
+
+	// IgnoreDefaults lists the fields of the source's defaults that the call
+	// doesn't use: "author", "subject", "destinations" or "triggers".
+	IgnoreDefaults []string `json:"ignore_defaults,omitempty" yaml:"ignore_defaults,omitempty" toml:"ignore_defaults,omitempty"`
 
-	Campaign Campaign `json:"campaign,omitempty" yaml:"campaign,omitempty"`
+	Campaign Campaign `json:"campaign,omitempty" yaml:"campaign,omitempty" toml:"campaign,omitempty"`
 
 	// Fields for expanded calls, not to be set in YAML
-	ScheduledAt time.Time `json:"-" yaml:"-"`
+	ScheduledAt time.Time `json:"-" yaml:"-" toml:"-"`
+	// ThreadID is the ID of the first expanded call for the same sequence and
+	// event, so that the calls in a sequence can be grouped together.
+	ThreadID string `json:"-" yaml:"-" toml:"-"`
+	// Event is the event that triggered the call, if any.
+	Event *Event `json:"-" yaml:"-" toml:"-"`
+	// SourceURL is the URL of the source that the call was read from.
+	SourceURL string `json:"-" yaml:"-" toml:"-"`
 }
 
 // Event represents an event invocation.
 type Event struct {
-	Destinations []Destination `json:"destinations,omitempty" yaml:"destinations,omitempty"`
-	Sequence     string        `json:"sequence" yaml:"sequence"`
-	StartTime    time.Time     `json:"start_time" yaml:"start_time"`
+	// ID identifies the event across changes to its start time. It defaults to
+	// the sequence, so it only needs to be set when a sequence has several events.
+	ID           string        `json:"id,omitempty" yaml:"id,omitempty" toml:"id,omitempty"`
+	Title        string        `json:"title,omitempty" yaml:"title,omitempty" toml:"title,omitempty"`
+	Destinations []Destination `json:"destinations,omitempty" yaml:"destinations,omitempty" toml:"destinations,omitempty"`
+	Sequence     string        `json:"sequence" yaml:"sequence" toml:"sequence"`
+	StartTime    time.Time     `json:"start_time" yaml:"start_time" toml:"start_time"`
+	EndTime      time.Time     `json:"end_time,omitempty" yaml:"end_time,omitempty" toml:"end_time,omitempty"`
+	Duration     string        `json:"duration,omitempty" yaml:"duration,omitempty" toml:"duration,omitempty"`
+
+	// Invite attaches a calendar invite for the event to email calls.
+	Invite bool `json:"invite,omitempty" yaml:"invite,omitempty" toml:"invite,omitempty"`
 }
 
 // Campaign represents a campaign.
 type Campaign struct {
-	ID   string `json:"id" yaml:"id"`
-	Name string `json:"name" yaml:"name"`
+	ID   string `json:"id" yaml:"id" toml:"id"`
+	Name string `json:"name" yaml:"name" toml:"name"`
+
+	// LegacyID is the ID that older versions derived from the file name, if
+	// it differs from ID, so that calls they sent are not sent again.
+	LegacyID string `json:"-" yaml:"-" toml:"-"`
 }
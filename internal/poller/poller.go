@@ -1,55 +1,308 @@
 package poller
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/andrewhowdencom/ruf/internal/sourcer"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultConcurrency is the default number of sources polled at once.
+	DefaultConcurrency = 4
+	// DefaultTimeout is the default time a source is given to be fetched and
+	// parsed.
+	DefaultTimeout = time.Minute
+	// DefaultMaxBackoff is the default longest time a failing source is
+	// skipped for.
+	DefaultMaxBackoff = time.Hour
 )
 
 // Poller periodically checks for updates in a list of sources.
 type Poller struct {
-	sourcer    sourcer.Sourcer
-	interval   time.Duration
+	sourcer     sourcer.Sourcer
+	interval    time.Duration
+	concurrency int
+	timeout     time.Duration
+	maxBackoff  time.Duration
+
+	// slots limits the fetches that run at once, across polls. A fetch holds
+	// its slot until it returns, even after it times out, so that fetches
+	// that hang can't pile up.
+	slots chan struct{}
+
+	mu         sync.Mutex
 	knownState map[string]string
+	// includes is the URLs of the files that each source includes.
+	includes map[string][]string
+	// inFlight are the URLs that are being listed or sourced, keyed by the
+	// operation, including those that timed out but haven't returned yet.
+	inFlight       map[string]bool
+	listFailures   map[string]*failure
+	sourceFailures map[string]*failure
 }
 
-// New creates a new Poller.
+// failure records the consecutive failures of a URL.
+type failure struct {
+	attempts int
+	// skip is the number of polls that the URL is skipped for.
+	skip int
+}
+
+// New creates a new Poller. Up to "source.concurrency" sources are polled at
+// once, each within "source.timeout". Sources that keep failing are skipped
+// for twice as many polls after each failure, for up to "source.max_backoff".
 func New(sourcer sourcer.Sourcer, interval time.Duration) *Poller {
+	concurrency := viper.GetInt("source.concurrency")
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	timeout := viper.GetDuration("source.timeout")
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	maxBackoff := viper.GetDuration("source.max_backoff")
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
 	return &Poller{
-		sourcer:    sourcer,
-		interval:   interval,
-		knownState: make(map[string]string),
+		sourcer:        sourcer,
+		interval:       interval,
+		concurrency:    concurrency,
+		timeout:        timeout,
+		maxBackoff:     maxBackoff,
+		slots:          make(chan struct{}, concurrency),
+		knownState:     make(map[string]string),
+		includes:       make(map[string][]string),
+		inFlight:       make(map[string]bool),
+		listFailures:   make(map[string]*failure),
+		sourceFailures: make(map[string]*failure),
 	}
 }
 
 // Poll checks for updates in the sources and returns the calls from the changed URLs.
+// URLs that refer to more than one file, such as directories and globs, are
+// expanded on every poll, so new files are picked up without a restart.
+// Sources are polled concurrently, and sources that fail are logged and
+// skipped, rather than failing the poll.
 func (p *Poller) Poll(urls []string) ([]*sourcer.Source, error) {
+	files := p.expand(urls)
+
+	sources := make([]*sourcer.Source, len(files))
+	p.each(len(files), func(i int) {
+		sources[i] = p.pollURL(files[i])
+	})
+
+	// Files that another source includes, such as a file in a directory that
+	// is also listed, are only used through that source, so that their calls
+	// are not sent twice.
+	p.mu.Lock()
+	includes := make(map[string][]string, len(files))
+	for _, file := range files {
+		includes[file] = p.includes[file]
+	}
+	p.mu.Unlock()
+
 	var allSources []*sourcer.Source
-	for _, url := range urls {
-		source, err := p.pollURL(url)
-		if err != nil {
-			// If a source can't be found, we log the error and continue.
-			fmt.Printf("Error checking source %s: %v\n", url, err)
+	for i, source := range sources {
+		if source == nil {
 			continue
 		}
-		if source != nil {
-			allSources = append(allSources, source)
+		if parent, ok := sourcer.IncludedBy(files[i], includes); ok {
+			slog.Warn("skipping source that is included by another source", "url", files[i], "included_by", parent)
+			continue
 		}
+		allSources = append(allSources, source)
 	}
 	return allSources, nil
 }
 
-func (p *Poller) pollURL(url string) (*sourcer.Source, error) {
-	source, state, err := p.sourcer.Source(url)
+// expand expands the URLs into the URLs of each source file.
+func (p *Poller) expand(urls []string) []string {
+	expanded := make([][]string, len(urls))
+	p.each(len(urls), func(i int) {
+		url := urls[i]
+		if !p.ready(p.listFailures, url) {
+			return
+		}
+
+		var files []string
+		err := p.withTimeout("list "+url, func() (err error) {
+			files, err = sourcer.List(p.sourcer, url)
+			return err
+		})
+		if err != nil {
+			p.fail(p.listFailures, url, "failed to list source", err)
+			return
+		}
+		p.succeed(p.listFailures, url)
+		expanded[i] = files
+	})
+
+	var all []string
+	for _, files := range expanded {
+		all = append(all, files...)
+	}
+	return all
+}
+
+// pollURL returns the source at a URL if it changed since the last poll.
+func (p *Poller) pollURL(url string) *sourcer.Source {
+	if !p.ready(p.sourceFailures, url) {
+		return nil
+	}
+
+	var source *sourcer.Source
+	var state string
+	err := p.withTimeout("source "+url, func() (err error) {
+		source, state, err = p.sourcer.Source(url)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		msg := "failed to poll source"
+		// Sources that fail verification are logged apart from sources that
+		// can't be fetched, as they may have been tampered with.
+		if errors.Is(err, sourcer.ErrUnverified) {
+			msg = "rejected source"
+		}
+		p.fail(p.sourceFailures, url, msg, err)
+		return nil
 	}
+	p.succeed(p.sourceFailures, url)
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.includes[url] = source.Included
 	if p.knownState[url] == state {
-		return nil, nil // No change
+		return nil // No change
+	}
+
+	// Warnings are logged when the source changes, rather than on every poll.
+	for _, diag := range source.Warnings {
+		slog.Warn("warning in source", "url", url, "warning", diag.String())
 	}
 
 	p.knownState[url] = state
-	return source, nil
+	return source
+}
+
+// each calls fn for 0 to n-1, running up to the concurrency limit at once.
+func (p *Poller) each(n int, fn func(i int)) {
+	sem := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// withTimeout runs fn in a free slot, giving up on it after the timeout. The
+// sourcer can't be cancelled, so fn keeps running after a timeout, holding its
+// slot, and the key is not run again until it returns. If no slot frees up
+// within the timeout, as other fetches are hung, fn is not run at all.
+func (p *Poller) withTimeout(key string, fn func() error) error {
+	p.mu.Lock()
+	if p.inFlight[key] {
+		p.mu.Unlock()
+		return fmt.Errorf("still running after the last poll")
+	}
+	p.inFlight[key] = true
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+	case <-timer.C:
+		p.mu.Lock()
+		delete(p.inFlight, key)
+		p.mu.Unlock()
+		return fmt.Errorf("no free slot after %s, as %d fetches are still running", p.timeout, len(p.slots))
+	}
+	timer.Reset(p.timeout)
+
+	done := make(chan error, 1)
+	go func() {
+		err := fn()
+		<-p.slots
+		p.mu.Lock()
+		delete(p.inFlight, key)
+		p.mu.Unlock()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("timed out after %s", p.timeout)
+	}
+}
+
+// ready reports whether a URL should be polled, or skipped as it is backing
+// off after failures.
+func (p *Poller) ready(failures map[string]*failure, url string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, ok := failures[url]
+	if !ok || f.skip == 0 {
+		return true
+	}
+	f.skip--
+	return false
+}
+
+// fail records and logs a failure of a URL, and backs it off exponentially.
+func (p *Poller) fail(failures map[string]*failure, url, msg string, err error) {
+	p.mu.Lock()
+	f, ok := failures[url]
+	if !ok {
+		f = &failure{}
+		failures[url] = f
+	}
+	f.attempts++
+	f.skip = p.skips(f.attempts)
+	attempts, retryIn := f.attempts, time.Duration(f.skip+1)*p.interval
+	p.mu.Unlock()
+
+	slog.Error(msg, "url", url, "attempt", attempts, "retry_in", retryIn, "error", err)
+}
+
+// succeed clears the failures of a URL.
+func (p *Poller) succeed(failures map[string]*failure, url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if f, ok := failures[url]; ok {
+		slog.Info("source recovered", "url", url, "attempts", f.attempts)
+		delete(failures, url)
+	}
+}
+
+// skips returns the number of polls that a URL is skipped for after a number
+// of consecutive failures: none after the first failure, then 1, 3, 7 and so
+// on, so that the time between attempts doubles, up to the maximum backoff.
+func (p *Poller) skips(attempts int) int {
+	maxSkips := 0
+	if p.interval > 0 {
+		maxSkips = int(p.maxBackoff/p.interval) - 1
+	}
+
+	skips := 0
+	for i := 1; i < attempts && skips < maxSkips; i++ {
+		skips = skips*2 + 1
+	}
+	return max(min(skips, maxSkips), 0)
 }
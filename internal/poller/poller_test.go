@@ -0,0 +1,281 @@
+package poller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/sourcer"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSourcer returns a source for every URL, after calling its hook.
+type fakeSourcer struct {
+	mu    sync.Mutex
+	calls map[string]int
+	hook  func(url string) error
+}
+
+func (s *fakeSourcer) Source(url string) (*sourcer.Source, string, error) {
+	s.mu.Lock()
+	s.calls[url]++
+	state := fmt.Sprint(s.calls[url])
+	s.mu.Unlock()
+
+	if s.hook != nil {
+		if err := s.hook(url); err != nil {
+			return nil, "", err
+		}
+	}
+	return &sourcer.Source{}, state, nil
+}
+
+func (s *fakeSourcer) count(url string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[url]
+}
+
+// captureLogs returns the structured entries logged during a test.
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&lockedWriter{mu: &mu, w: &buf}, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var entries []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+func TestPoller_Concurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	s := &fakeSourcer{calls: make(map[string]int), hook: func(string) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}}
+	viper.Set("source.concurrency", 3)
+	defer viper.Set("source.concurrency", nil)
+	p := New(s, time.Minute)
+
+	var urls []string
+	for i := 0; i < 9; i++ {
+		urls = append(urls, fmt.Sprintf("file:///campaigns/%d.yaml", i))
+	}
+	sources, err := p.Poll(urls)
+	require.NoError(t, err)
+	assert.Len(t, sources, 9)
+	assert.Equal(t, 3, peak)
+
+	// Unchanged sources are not returned.
+	s.hook = nil
+	p.knownState = map[string]string{urls[0]: "2"}
+	sources, err = p.Poll(urls)
+	require.NoError(t, err)
+	assert.Len(t, sources, 8)
+}
+
+func TestPoller_Timeout(t *testing.T) {
+	logs := captureLogs(t)
+	release := make(chan struct{})
+	defer close(release)
+	s := &fakeSourcer{calls: make(map[string]int), hook: func(url string) error {
+		if url == "https://example.com/slow.yaml" {
+			<-release
+		}
+		return nil
+	}}
+	p := New(s, time.Minute)
+	p.timeout = 20 * time.Millisecond
+
+	urls := []string{"https://example.com/slow.yaml", "https://example.com/fast.yaml"}
+	sources, err := p.Poll(urls)
+	require.NoError(t, err)
+	assert.Len(t, sources, 1)
+
+	// The slow source isn't fetched again while it is still running.
+	_, err = p.Poll(urls)
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.count("https://example.com/slow.yaml"))
+	assert.Equal(t, 2, s.count("https://example.com/fast.yaml"))
+
+	entries := logs()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "failed to poll source", entries[0]["msg"])
+	assert.Equal(t, "https://example.com/slow.yaml", entries[0]["url"])
+	assert.Equal(t, float64(1), entries[0]["attempt"])
+	assert.Equal(t, "timed out after 20ms", entries[0]["error"])
+	assert.Equal(t, float64(2), entries[1]["attempt"])
+	assert.Equal(t, "still running after the last poll", entries[1]["error"])
+}
+
+func TestPoller_TimeoutHoldsSlot(t *testing.T) {
+	captureLogs(t)
+	release := make(chan struct{})
+	s := &fakeSourcer{calls: make(map[string]int), hook: func(url string) error {
+		if url == "https://example.com/hung.yaml" {
+			<-release
+		}
+		return nil
+	}}
+	viper.Set("source.concurrency", 1)
+	defer viper.Set("source.concurrency", nil)
+	p := New(s, time.Minute)
+	p.timeout = 20 * time.Millisecond
+
+	// The hung fetch keeps its slot after it times out, so other sources
+	// aren't fetched alongside it.
+	sources, err := p.Poll([]string{"https://example.com/hung.yaml", "https://example.com/other.yaml"})
+	require.NoError(t, err)
+	assert.Empty(t, sources)
+	assert.Equal(t, 0, s.count("https://example.com/other.yaml"))
+
+	// Once it returns, its slot is free again.
+	close(release)
+	assert.Eventually(t, func() bool { return len(p.slots) == 0 }, time.Second, time.Millisecond)
+	sources, err = p.Poll([]string{"https://example.com/other.yaml"})
+	require.NoError(t, err)
+	assert.Len(t, sources, 1)
+}
+
+func TestPoller_Backoff(t *testing.T) {
+	logs := captureLogs(t)
+	failing := true
+	s := &fakeSourcer{calls: make(map[string]int), hook: func(url string) error {
+		if url == "https://example.com/broken.yaml" && failing {
+			return errors.New("status code 500")
+		}
+		if url == "https://example.com/unsigned.yaml" {
+			return fmt.Errorf("%w: %s: no signature", sourcer.ErrUnverified, url)
+		}
+		return nil
+	}}
+	p := New(s, time.Minute)
+	p.maxBackoff = 8 * time.Minute
+
+	// The broken source is attempted on polls 1, 2, 4, 8 and 16, and then
+	// every 8 polls, as the backoff is capped at 8 minutes.
+	var attempted []int
+	for poll := 1; poll <= 24; poll++ {
+		before := s.count("https://example.com/broken.yaml")
+		_, err := p.Poll([]string{"https://example.com/broken.yaml"})
+		require.NoError(t, err)
+		if s.count("https://example.com/broken.yaml") > before {
+			attempted = append(attempted, poll)
+		}
+	}
+	assert.Equal(t, []int{1, 2, 4, 8, 16, 24}, attempted)
+
+	entries := logs()
+	require.Len(t, entries, 6)
+	assert.Equal(t, float64(4), entries[3]["attempt"])
+	assert.Equal(t, float64(8*time.Minute), entries[3]["retry_in"])
+	assert.Equal(t, "status code 500", entries[3]["error"])
+
+	// A success resets the backoff.
+	failing = false
+	p.sourceFailures["https://example.com/broken.yaml"].skip = 0
+	sources, err := p.Poll([]string{"https://example.com/broken.yaml"})
+	require.NoError(t, err)
+	assert.Len(t, sources, 1)
+	assert.Empty(t, p.sourceFailures)
+	assert.Equal(t, "source recovered", logs()[6]["msg"])
+
+	// Sources that fail verification are logged as rejected.
+	_, err = p.Poll([]string{"https://example.com/unsigned.yaml"})
+	require.NoError(t, err)
+	entry := logs()[7]
+	assert.Equal(t, "rejected source", entry["msg"])
+	assert.Equal(t, "https://example.com/unsigned.yaml", entry["url"])
+}
+
+func TestPoller_Skips(t *testing.T) {
+	p := &Poller{interval: time.Minute, maxBackoff: time.Hour}
+	var skips []int
+	for attempts := 1; attempts <= 8; attempts++ {
+		skips = append(skips, p.skips(attempts))
+	}
+	assert.Equal(t, []int{0, 1, 3, 7, 15, 31, 59, 59}, skips)
+
+	// Backoffs shorter than the interval don't skip polls.
+	p = &Poller{interval: time.Hour, maxBackoff: time.Minute}
+	assert.Equal(t, 0, p.skips(5))
+}
+
+func TestPoller_Included(t *testing.T) {
+	logs := captureLogs(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"release.yaml":              "include: [reminders/]\ncalls: [{id: announce}]\n",
+		"reminders/day-before.yaml": "calls: [{id: day-before}]\n",
+		"other.yaml":                "calls: [{id: other}]\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	fetcher := sourcer.NewCompositeFetcher()
+	fetcher.AddFetcher("file", sourcer.NewFileFetcher())
+	p := New(sourcer.NewSourcer(fetcher, sourcer.NewParsers(false)), time.Minute)
+
+	// The included file is only used through the file that includes it, even
+	// though the directory it is in is listed too.
+	sources, err := p.Poll([]string{"file://" + dir + "/release.yaml", "file://" + dir + "/reminders/", "file://" + dir + "/other.yaml"})
+	require.NoError(t, err)
+	var ids []string
+	for _, source := range sources {
+		for _, call := range source.Calls {
+			ids = append(ids, call.ID)
+		}
+	}
+	assert.Equal(t, []string{"announce", "day-before", "other"}, ids)
+
+	entries := logs()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "skipping source that is included by another source", entries[0]["msg"])
+	assert.Equal(t, "file://"+dir+"/reminders/day-before.yaml", entries[0]["url"])
+	assert.Equal(t, "file://"+dir+"/release.yaml", entries[0]["included_by"])
+}
@@ -0,0 +1,88 @@
+package sourcer
+
+import (
+	"slices"
+
+	"github.com/andrewhowdencom/ruf/internal/model"
+)
+
+// Defaults are the fields that every call in a source file defaults to. They
+// are merged into each call when the file is parsed:
+//
+//   - Author and subject are used by calls that don't set them.
+//   - Destinations are appended to the call's destinations. A destination of
+//     the same type as one of the call's is merged into it instead: its
+//     recipients and tags are appended, and its other options are used if
+//     the call's destination doesn't set them.
+//   - Triggers are only used by calls without triggers of their own, rather
+//     than appended, as extra triggers would send a call more often.
+//
+// Calls leave out the fields listed in their "ignore_defaults", such as
+// "destinations" for a call that is only sent to its own destinations.
+// Defaults don't apply to the destinations of events.
+type Defaults struct {
+	Author       string              `json:"author,omitempty" yaml:"author,omitempty" toml:"author,omitempty"`
+	Subject      string              `json:"subject,omitempty" yaml:"subject,omitempty" toml:"subject,omitempty"`
+	Destinations []model.Destination `json:"destinations,omitempty" yaml:"destinations,omitempty" toml:"destinations,omitempty"`
+	Triggers     []model.Trigger     `json:"triggers,omitempty" yaml:"triggers,omitempty" toml:"triggers,omitempty"`
+}
+
+// apply merges the defaults into a call.
+func (d *Defaults) apply(c *model.Call) {
+	uses := func(field string) bool { return !slices.Contains(c.IgnoreDefaults, field) }
+
+	if c.Author == "" && uses("author") {
+		c.Author = d.Author
+	}
+	if c.Subject == "" && uses("subject") {
+		c.Subject = d.Subject
+	}
+	if len(c.Triggers) == 0 && uses("triggers") {
+		c.Triggers = slices.Clone(d.Triggers)
+	}
+	if !uses("destinations") {
+		return
+	}
+
+	// Copy the call's destinations, so that merging doesn't change the
+	// destinations of other calls that share them.
+	dests := make([]model.Destination, len(c.Destinations))
+	for i, dest := range c.Destinations {
+		dests[i] = cloneDestination(dest)
+	}
+	for _, def := range d.Destinations {
+		i := slices.IndexFunc(dests, func(dest model.Destination) bool { return dest.Type == def.Type })
+		if i < 0 {
+			dests = append(dests, cloneDestination(def))
+			continue
+		}
+		dest := &dests[i]
+		dest.To = appendUnique(dest.To, def.To...)
+		dest.Tags = appendUnique(dest.Tags, def.Tags...)
+		if dest.Priority == 0 {
+			dest.Priority = def.Priority
+		}
+		if dest.Click == "" {
+			dest.Click = def.Click
+		}
+	}
+	c.Destinations = dests
+}
+
+// cloneDestination returns a copy of a destination that doesn't share its
+// lists.
+func cloneDestination(d model.Destination) model.Destination {
+	d.To = slices.Clone(d.To)
+	d.Tags = slices.Clone(d.Tags)
+	return d
+}
+
+// appendUnique appends the values that are not in a list yet.
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
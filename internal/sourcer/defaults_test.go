@@ -0,0 +1,104 @@
+package sourcer
+
+import (
+	"testing"
+
+	"github.com/andrewhowdencom/ruf/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsers_Defaults(t *testing.T) {
+	source, err := NewParsers(false).Parse("file:///campaigns/release.yaml", []byte(`
+defaults:
+  author: releases@example.com
+  subject: Release
+  destinations:
+    - type: slack
+      to: ["#releases"]
+    - type: ntfy
+      to: [releases]
+      priority: 4
+      tags: [tada]
+  triggers:
+    - cron: "0 9 * * 1"
+calls:
+  - id: default
+    content: Everything from the defaults.
+  - id: merged
+    author: someone@example.com
+    content: Merged with the defaults.
+    destinations:
+      - type: slack
+        to: ["#general", "#releases"]
+      - type: ntfy
+        to: [urgent]
+        priority: 5
+        tags: [warning]
+      - type: email
+        to: [team@example.com]
+    triggers:
+      - scheduled_at: 2025-01-01T12:00:00Z
+`))
+	require.NoError(t, err)
+	require.Len(t, source.Calls, 2)
+
+	call := source.Calls[0]
+	assert.Equal(t, "releases@example.com", call.Author)
+	assert.Equal(t, "Release", call.Subject)
+	assert.Equal(t, []model.Destination{
+		{Type: "slack", To: []string{"#releases"}},
+		{Type: "ntfy", To: []string{"releases"}, Priority: 4, Tags: []string{"tada"}},
+	}, call.Destinations)
+	assert.Equal(t, []model.Trigger{{Cron: "0 9 * * 1"}}, call.Triggers)
+	assert.Equal(t, "release", call.Campaign.ID)
+
+	// Calls keep their own values, their destinations are merged with the
+	// defaults, and their triggers replace the defaults.
+	call = source.Calls[1]
+	assert.Equal(t, "someone@example.com", call.Author)
+	assert.Equal(t, "Release", call.Subject)
+	assert.Equal(t, []model.Destination{
+		{Type: "slack", To: []string{"#general", "#releases"}},
+		{Type: "ntfy", To: []string{"urgent", "releases"}, Priority: 5, Tags: []string{"warning", "tada"}},
+		{Type: "email", To: []string{"team@example.com"}},
+	}, call.Destinations)
+	require.Len(t, call.Triggers, 1)
+	assert.Empty(t, call.Triggers[0].Cron)
+
+	// Merging doesn't change the defaults that other calls share.
+	assert.Equal(t, []string{"#releases"}, source.Defaults.Destinations[0].To)
+	source.Calls[0].Destinations[0].To[0] = "#changed"
+	assert.Equal(t, "#releases", source.Defaults.Destinations[0].To[0])
+}
+
+func TestParsers_IgnoreDefaults(t *testing.T) {
+	source, err := NewParsers(true).Parse("file:///campaigns/release.yaml", []byte(`
+defaults:
+  author: releases@example.com
+  destinations:
+    - type: slack
+      to: ["#releases"]
+  triggers:
+    - cron: "0 9 * * 1"
+calls:
+  - id: private
+    content: Only sent to its own destinations.
+    ignore_defaults: [destinations, author]
+    destinations:
+      - type: slack
+        to: ["#security"]
+`))
+	require.NoError(t, err)
+	require.Len(t, source.Calls, 1)
+
+	call := source.Calls[0]
+	assert.Empty(t, call.Author)
+	assert.Equal(t, []model.Destination{{Type: "slack", To: []string{"#security"}}}, call.Destinations)
+	assert.Equal(t, []model.Trigger{{Cron: "0 9 * * 1"}}, call.Triggers)
+}
+
+func TestParsers_DefaultsUnknownField(t *testing.T) {
+	_, err := NewParsers(true).Parse("file:///campaigns/release.yaml", []byte("defaults:\n  auther: releases@example.com\ncalls: []\n"))
+	assert.ErrorContains(t, err, `/campaigns/release.yaml:2:3: unknown field "auther" in defaults, did you mean "author"?`)
+}
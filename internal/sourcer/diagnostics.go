@@ -0,0 +1,235 @@
+package sourcer
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLine matches the line number in the errors of the YAML decoder.
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Diagnostic is a problem found in a source file.
+type Diagnostic struct {
+	URL     string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String formats the diagnostic as "<file>:<line>:<column>: <message>", as
+// compilers do, leaving out the position if it is not known.
+func (d Diagnostic) String() string {
+	name := d.URL
+	if u, err := url.Parse(d.URL); err == nil && u.Scheme == "file" {
+		name = u.Path
+	}
+
+	switch {
+	case d.Line == 0:
+		return fmt.Sprintf("%s: %s", name, d.Message)
+	case d.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", name, d.Line, d.Message)
+	default:
+		return fmt.Sprintf("%s:%d:%d: %s", name, d.Line, d.Column, d.Message)
+	}
+}
+
+// Diagnostics is an error listing every problem found in a source file.
+type Diagnostics []Diagnostic
+
+// Error returns the diagnostics, one per line.
+func (d Diagnostics) Error() string {
+	lines := make([]string, len(d))
+	for i, diag := range d {
+		lines[i] = diag.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkNode reports the keys in a YAML node that are not fields of the type
+// that it is decoded into.
+func checkNode(rawURL string, node *yaml.Node, t reflect.Type) Diagnostics {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var diags Diagnostics
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			diags = append(diags, checkNode(rawURL, child, t)...)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		for _, child := range node.Content {
+			diags = append(diags, checkNode(rawURL, child, t.Elem())...)
+		}
+	case yaml.MappingNode:
+		switch {
+		case t.Kind() == reflect.Map:
+			for i := 1; i < len(node.Content); i += 2 {
+				diags = append(diags, checkNode(rawURL, node.Content[i], t.Elem())...)
+			}
+		case t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{}):
+			fields := fieldTypes(t)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if key.Value == "<<" {
+					// Merge keys are checked where they are defined.
+					continue
+				}
+				ft, ok := fields[key.Value]
+				if !ok {
+					diags = append(diags, Diagnostic{
+						URL:     rawURL,
+						Line:    key.Line,
+						Column:  key.Column,
+						Message: unknownField(key.Value, t),
+					})
+					continue
+				}
+				diags = append(diags, checkNode(rawURL, value, ft)...)
+			}
+		}
+	}
+	return diags
+}
+
+// fieldAt returns the type of the value at a path of keys in a type, and
+// whether it exists. Slices are looked through, as the keys of tables in
+// arrays don't include an index.
+func fieldAt(t reflect.Type, keys []string) (reflect.Type, bool) {
+	for _, key := range keys {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			ft, ok := fieldTypes(t)[key]
+			if !ok {
+				return nil, false
+			}
+			t = ft
+		default:
+			return nil, false
+		}
+	}
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t, true
+}
+
+// fieldTypes returns the types of the fields of a struct by their key. The
+// keys are the same in every format.
+func fieldTypes(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// unknownField returns the message for a key that is not a field of a type,
+// suggesting the field that it is most likely a misspelling of.
+func unknownField(key string, t reflect.Type) string {
+	msg := fmt.Sprintf("unknown field %q in %s", key, strings.ToLower(t.Name()))
+
+	var candidates []string
+	for name := range fieldTypes(t) {
+		candidates = append(candidates, name)
+	}
+	if suggestion := suggest(key, candidates); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	return msg
+}
+
+// suggest returns the candidate closest to a name, if it is close enough to be
+// a misspelling of it.
+func suggest(name string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		d := levenshtein(strings.ToLower(name), candidate)
+		if bestDistance == -1 || d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	if bestDistance == -1 || bestDistance > max(2, len(name)/3) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// yamlDiagnostics converts an error from the YAML decoder into diagnostics.
+func yamlDiagnostics(rawURL string, err error) Diagnostics {
+	messages := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	}
+
+	var diags Diagnostics
+	for _, msg := range messages {
+		diag := Diagnostic{URL: rawURL, Message: strings.TrimPrefix(msg, "yaml: ")}
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			diag.Line, _ = strconv.Atoi(m[1])
+			diag.Message = m[2]
+		}
+		diags = append(diags, diag)
+	}
+	return diags
+}
+
+// position returns the line and column of a byte offset in data.
+func position(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
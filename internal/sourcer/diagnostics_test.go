@@ -0,0 +1,112 @@
+package sourcer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsers_Strict(t *testing.T) {
+	testCases := []struct {
+		name     string
+		url      string
+		data     string
+		expected []string
+	}{
+		{
+			name: "yaml",
+			url:  "file:///etc/ruf/calls.yaml",
+			data: `calls:
+  - id: "release"
+    contnet: "Hello!"
+    destination:
+      - type: "slack"
+        to: ["#general"]
+    triggers:
+      - schedule_at: "2025-01-01T12:00:00Z"
+        colour: "blue"
+`,
+			expected: []string{
+				`/etc/ruf/calls.yaml:3:5: unknown field "contnet" in call, did you mean "content"?`,
+				`/etc/ruf/calls.yaml:4:5: unknown field "destination" in call, did you mean "destinations"?`,
+				`/etc/ruf/calls.yaml:8:9: unknown field "schedule_at" in trigger, did you mean "scheduled_at"?`,
+				`/etc/ruf/calls.yaml:9:9: unknown field "colour" in trigger`,
+			},
+		},
+		{
+			name: "json",
+			url:  "https://example.com/calls.json",
+			data: `{
+  "campain": {"id": "releases"},
+  "calls": [{"id": "release", "triggers": [{"crom": "0 9 * * *"}]}]
+}`,
+			expected: []string{
+				`https://example.com/calls.json:2:3: unknown field "campain" in source, did you mean "campaign"?`,
+				`https://example.com/calls.json:3:45: unknown field "crom" in trigger, did you mean "cron"?`,
+			},
+		},
+		{
+			name: "toml",
+			url:  "file:///etc/ruf/calls.toml",
+			data: `[[calls]]
+id = "release"
+subjet = "Hello"
+
+[[calls.destinations]]
+type = "email"
+too = ["all@example.com"]
+`,
+			expected: []string{
+				`/etc/ruf/calls.toml:3:1: unknown field "subjet" in call, did you mean "subject"?`,
+				`/etc/ruf/calls.toml:7:1: unknown field "too" in destination, did you mean "to"?`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewParsers(true).Parse(tc.url, []byte(tc.data))
+			var diags Diagnostics
+			require.ErrorAs(t, err, &diags)
+			var lines []string
+			for _, diag := range diags {
+				lines = append(lines, diag.String())
+			}
+			assert.Equal(t, tc.expected, lines)
+
+			// Without strict mode, the source is used and the problems are warnings.
+			source, err := NewParsers(false).Parse(tc.url, []byte(tc.data))
+			require.NoError(t, err)
+			assert.Equal(t, diags, source.Warnings)
+			assert.Equal(t, "release", source.Calls[0].ID)
+		})
+	}
+}
+
+func TestParsers_SyntaxErrors(t *testing.T) {
+	testCases := []struct {
+		url      string
+		data     string
+		expected string
+	}{
+		{"file:///calls.yaml", "calls:\n  - id: a\n b: c\n", "/calls.yaml:2: did not find expected key"},
+		{"file:///calls.yaml", "calls:\n  - id: [a]\n", "/calls.yaml:2: cannot unmarshal !!seq into string"},
+		{"file:///calls.json", "{\n  \"calls\": [\n    {\"id\": 1}\n  ]\n}", "/calls.json:3:12: cannot unmarshal number into Go struct field"},
+		{"file:///calls.json", "{\n  \"calls\": [,]\n}", "/calls.json:2:13: invalid character ',' looking for beginning of value"},
+		{"file:///calls.toml", "[[calls]]\nid = 1\n", "/calls.toml:2:6: cannot decode TOML integer into struct field model.Call.ID of type string"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			_, err := NewParsers(false).Parse(tc.url, []byte(tc.data))
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"id", "author", "subject", "content", "destinations", "triggers", "campaign"}
+	assert.Equal(t, "triggers", suggest("trigger", candidates))
+	assert.Equal(t, "author", suggest("Auther", candidates))
+	assert.Equal(t, "id", suggest("ids", candidates))
+	assert.Equal(t, "", suggest("priority", candidates))
+}
@@ -1,72 +1,402 @@
 package sourcer
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"log/slog"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/adrg/xdg"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/spf13/viper"
 )
 
+// defaultFetchInterval is how long a cached repository is used before it is
+// fetched again, so that the URLs to one repository in a poll share a fetch.
+const defaultFetchInterval = 10 * time.Second
+
 // GitFetcher is an implementation of Fetcher that fetches content from a git repository.
-type GitFetcher struct{}
+// Repositories are cloned once into a cache, and fetched incrementally after
+// that. The cache is shared by every URL that points at the same repository
+// and ref.
+type GitFetcher struct {
+	cacheDir      string
+	fetchInterval time.Duration
+	now           func() time.Time
+
+	mu    sync.Mutex
+	repos map[string]*cachedRepo
+}
 
-// NewGitFetcher creates a new GitFetcher.
+// cachedRepo is the state of a repository in the cache.
+type cachedRepo struct {
+	// mu is held while the repository is updated or read.
+	mu      sync.Mutex
+	fetched time.Time
+}
+
+// NewGitFetcher creates a new GitFetcher. Repositories are cached in
+// "git.cache_dir", or in "ruf/git" in the user's cache directory (usually
+// $XDG_CACHE_HOME) if it is not set.
 func NewGitFetcher() *GitFetcher {
-	return &GitFetcher{}
+	cacheDir := viper.GetString("git.cache_dir")
+	if cacheDir == "" {
+		cacheDir = defaultGitCacheDir()
+	}
+	return &GitFetcher{
+		cacheDir:      cacheDir,
+		fetchInterval: defaultFetchInterval,
+		now:           time.Now,
+		repos:         make(map[string]*cachedRepo),
+	}
+}
+
+// defaultGitCacheDir returns the default directory that repositories are
+// cached in.
+func defaultGitCacheDir() string {
+	dir, err := xdg.CacheFile("ruf/git")
+	if err != nil {
+		return filepath.Join(xdg.CacheHome, "ruf", "git")
+	}
+	return dir
+}
+
+// gitLocation is a location in a git repository, parsed from a URL in one of
+// two formats:
+//
+//   - git+<transport>://<host>/<repo>//<path>?ref=<ref>, where the transport is
+//     https, http, ssh or file, and the ref defaults to the default branch of
+//     the repository. For example:
+//     git+ssh://git@gitlab.example.com/group/sub/repo.git//campaigns/a.yaml?ref=main
+//   - git://<host>/<user>/<repo>/tree/<ref>/<path>, for repositories cloned
+//     over HTTPS with GitHub style paths.
+type gitLocation struct {
+	url       *url.URL
+	transport string
+	remote    string
+	ref       string
+	path      string
+	// prefix is the URL path before the path in the repository.
+	prefix string
+}
+
+// parseGitURL parses a git URL into a location.
+func parseGitURL(rawURL string) (*gitLocation, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+	}
+
+	transport, ok := strings.CutPrefix(u.Scheme, "git+")
+	if !ok {
+		// The path is in the format /<user>/<repo>/tree/<ref>/<path/to/file>
+		path := strings.TrimPrefix(u.Path, "/")
+		pathParts := strings.SplitN(path, "/", 5)
+		if len(pathParts) < 5 {
+			return nil, fmt.Errorf("invalid git url path: %s. Expected /<user>/<repo>/tree/<ref>/<file>", u.Path)
+		}
+
+		return &gitLocation{
+			url:       u,
+			transport: "https",
+			remote:    fmt.Sprintf("https://%s/%s/%s.git", u.Host, pathParts[0], pathParts[1]),
+			ref:       pathParts[3],
+			path:      pathParts[4],
+			prefix:    "/" + strings.Join(pathParts[:4], "/") + "/",
+		}, nil
+	}
+
+	switch transport {
+	case "https", "http", "ssh", "file":
+	default:
+		return nil, fmt.Errorf("invalid git url %s: unsupported transport '%s'", rawURL, transport)
+	}
+
+	repo, path, ok := strings.Cut(u.Path, "//")
+	if !ok || repo == "" || path == "" {
+		return nil, fmt.Errorf("invalid git url %s: expected <repo>//<file>", rawURL)
+	}
+
+	remote := &url.URL{Scheme: transport, Host: u.Host, Path: repo}
+	if transport == "ssh" {
+		// Credentials for HTTP are configured, but SSH needs the user.
+		remote.User = u.User
+	}
+
+	return &gitLocation{
+		url:       u,
+		transport: transport,
+		remote:    remote.String(),
+		ref:       u.Query().Get("ref"),
+		path:      path,
+		prefix:    repo + "//",
+	}, nil
+}
+
+// urlFor returns the URL of another path in the repository of a location, at
+// the same ref.
+func (l *gitLocation) urlFor(path string) string {
+	return withPath(l.url, l.prefix+path)
 }
 
 // Fetch fetches the content of a URL and returns it as a byte slice.
 func (f *GitFetcher) Fetch(rawURL string) ([]byte, string, error) {
-	u, err := url.Parse(rawURL)
+	loc, err := parseGitURL(rawURL)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+		return nil, "", err
 	}
 
-	// The path is in the format /<user>/<repo>/tree/<ref>/<path/to/file>
-	path := strings.TrimPrefix(u.Path, "/")
-	pathParts := strings.SplitN(path, "/", 5)
-	if len(pathParts) < 5 {
-		return nil, "", fmt.Errorf("invalid git url path: %s. Expected /<user>/<repo>/tree/<ref>/<file>", u.Path)
+	w, hash, cleanup, err := f.checkout(loc)
+	if err != nil {
+		return nil, "", err
 	}
-	user := pathParts[0]
-	repo := pathParts[1]
-	// pathParts[2] is "tree"
-	ref := pathParts[3]
-	filePath := pathParts[4]
+	defer cleanup()
 
-	cloneURL := fmt.Sprintf("https://%s/%s/%s.git", u.Host, user, repo)
+	file, err := w.Filesystem.Open(loc.path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open file '%s' in repo %s: %w", loc.path, loc.remote, err)
+	}
+	defer file.Close()
 
-	// Create a temporary directory
-	dir, err := ioutil.TempDir("", "ruf-git-sourcer")
+	data, err := io.ReadAll(file)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create temporary directory: %w", err)
+		return nil, "", fmt.Errorf("failed to read file '%s' in repo %s: %w", loc.path, loc.remote, err)
 	}
-	defer os.RemoveAll(dir)
 
-	cloneOptions := &git.CloneOptions{
-		URL:          cloneURL,
-		SingleBranch: true,
-		Depth:        1,
+	return data, hash, nil
+}
+
+// List expands a URL to a directory in a repository, or a glob such as
+// "git://github.com/user/repo/tree/main/campaigns/*.yaml", into the URLs of the
+// source files in it. Globs may only be used in the last element of the path,
+// and directories are not searched recursively. Any other URL is returned as
+// it is.
+func (f *GitFetcher) List(rawURL string) ([]string, error) {
+	loc, err := parseGitURL(rawURL)
+	if err != nil {
+		return nil, err
 	}
 
-	username := viper.GetString(fmt.Sprintf("git.auth.%s.username", u.Host))
-	token := viper.GetString(fmt.Sprintf("git.auth.%s.token", u.Host))
-	if token != "" {
-		cloneOptions.Auth = &http.BasicAuth{
-			Username: username,
-			Password: token,
+	dir, pattern := loc.path, ""
+	if hasMeta(loc.path) {
+		dir, pattern = path.Split(loc.path)
+		if hasMeta(dir) {
+			return nil, fmt.Errorf("invalid glob %s: globs are only supported in the last element of the path", rawURL)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %s: %w", rawURL, err)
 		}
+	} else if strings.HasSuffix(loc.path, "/") {
+		pattern = "*"
 	}
 
-	// Determine if the ref is a branch, tag, or commit hash
-	if len(ref) == 40 {
+	if pattern == "" {
+		// Avoid cloning the repository for URLs that are obviously files.
+		if isSourceFile(loc.path) {
+			return []string{rawURL}, nil
+		}
+		pattern = "*"
+	}
+
+	w, _, cleanup, err := f.checkout(loc)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		dir = "."
+	}
+	info, err := w.Filesystem.Stat(dir)
+	if err != nil || !info.IsDir() {
+		// Errors are reported when the file is fetched.
+		return []string{rawURL}, nil
+	}
+	entries, err := w.Filesystem.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s' in repo %s: %w", dir, loc.remote, err)
+	}
+
+	var urls []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isSourceFile(entry.Name()) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, entry.Name()); !ok {
+			continue
+		}
+		urls = append(urls, loc.urlFor(path.Join(dir, entry.Name())))
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+// CommitSignature returns the signature of a commit in the cached repository
+// of a URL, and the commit without its signature, which is the content that
+// the signature signs.
+func (f *GitFetcher) CommitSignature(rawURL, hash string) (string, []byte, error) {
+	loc, err := parseGitURL(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir := filepath.Join(f.cacheDir, cacheKey(loc.remote, loc.ref))
+	repo := f.repo(dir)
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open cached repo %s: %w", dir, err)
+	}
+	commit, err := r.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get commit %s in repo %s: %w", hash, loc.remote, err)
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return "", nil, fmt.Errorf("failed to encode commit %s: %w", hash, err)
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode commit %s: %w", hash, err)
+	}
+	defer reader.Close()
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode commit %s: %w", hash, err)
+	}
+
+	return commit.PGPSignature, payload, nil
+}
+
+// checkout returns the worktree of the cached repository of a location at its
+// ref, and the hash of the checked out commit. The repository is cloned if it
+// is not in the cache yet, and fetched if it was not fetched recently. If the
+// fetch fails, the last checkout is used. The repository is locked until the
+// cleanup function is called.
+func (f *GitFetcher) checkout(loc *gitLocation) (*git.Worktree, string, func(), error) {
+	remote := loc.remote
+	dir := filepath.Join(f.cacheDir, cacheKey(remote, loc.ref))
+
+	if err := os.MkdirAll(f.cacheDir, 0755); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create git cache directory: %w", err)
+	}
+
+	// Lock the repository against other goroutines, and against other
+	// processes that share the cache.
+	repo := f.repo(dir)
+	repo.mu.Lock()
+	unlockFile, err := lockFile(dir + ".lock")
+	if err != nil {
+		repo.mu.Unlock()
+		return nil, "", nil, fmt.Errorf("failed to lock git cache %s: %w", dir, err)
+	}
+	unlock := func() {
+		unlockFile()
+		repo.mu.Unlock()
+	}
+
+	r, err := git.PlainOpen(dir)
+	switch {
+	case errors.Is(err, git.ErrRepositoryNotExists):
+		r, err = f.clone(loc, dir)
+		if err != nil {
+			unlock()
+			return nil, "", nil, err
+		}
+		repo.fetched = f.now()
+	case err != nil:
+		unlock()
+		return nil, "", nil, fmt.Errorf("failed to open cached repo %s: %w", dir, err)
+	case f.now().Sub(repo.fetched) >= f.fetchInterval:
+		if err := f.update(r, loc); err != nil {
+			// Rejected credentials are reported, rather than hidden behind
+			// the last checkout, as they won't fix themselves.
+			if isAuthError(err) {
+				unlock()
+				return nil, "", nil, fmt.Errorf("failed to update repo %s: %w", remote, err)
+			}
+			slog.Warn("failed to update git repository, using the last checkout", "repo", remote, "ref", loc.ref, "error", err)
+		}
+		// Failed fetches are not retried until the interval has passed, so
+		// an unreachable remote doesn't slow down every URL that uses it.
+		repo.fetched = f.now()
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		unlock()
+		return nil, "", nil, fmt.Errorf("failed to get worktree for repo %s: %w", remote, err)
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		unlock()
+		return nil, "", nil, fmt.Errorf("failed to get head for repo %s: %w", remote, err)
+	}
+
+	return w, head.Hash().String(), unlock, nil
+}
+
+// repo returns the state of the cached repository in a directory.
+func (f *GitFetcher) repo(dir string) *cachedRepo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	repo, ok := f.repos[dir]
+	if !ok {
+		repo = &cachedRepo{}
+		f.repos[dir] = repo
+	}
+	return repo
+}
+
+// clone clones the repository of a location at its ref into a directory. The
+// repository is cloned into a temporary directory first, so that a failed
+// clone doesn't leave a broken repository in the cache.
+func (f *GitFetcher) clone(loc *gitLocation, dir string) (*git.Repository, error) {
+	ref, remote := loc.ref, loc.remote
+
+	auth, err := gitAuth(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp(f.cacheDir, ".clone-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	cloneOptions := &git.CloneOptions{
+		URL:          remote,
+		SingleBranch: true,
+		Depth:        1,
+		Auth:         auth,
+	}
+
+	// Determine if the ref is a branch, tag, or commit hash. Without a ref,
+	// the default branch is cloned.
+	if ref == "" {
+		cloneOptions.ReferenceName = ""
+	} else if len(ref) == 40 {
 		// This is a commit hash
 		cloneOptions.ReferenceName = plumbing.HEAD
 	} else {
@@ -74,54 +404,174 @@ func (f *GitFetcher) Fetch(rawURL string) ([]byte, string, error) {
 		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(ref)
 	}
 
-	r, err := git.PlainClone(dir, false, cloneOptions)
+	r, err := git.PlainClone(tmp, false, cloneOptions)
 	if err != nil {
 		// If it failed, try as a tag (and it wasn't a commit hash)
-		if len(ref) != 40 {
+		if ref == "" {
+			return nil, fmt.Errorf("failed to clone repo %s: %w", remote, err)
+		} else if len(ref) != 40 {
 			cloneOptions.ReferenceName = plumbing.NewTagReferenceName(ref)
-			r, err = git.PlainClone(dir, false, cloneOptions)
+			if err := os.RemoveAll(tmp); err != nil {
+				return nil, fmt.Errorf("failed to clean up temporary directory: %w", err)
+			}
+			r, err = git.PlainClone(tmp, false, cloneOptions)
 			if err != nil {
-				return nil, "", fmt.Errorf("failed to clone repo %s with ref %s (tried as branch and tag): %w", cloneURL, ref, err)
+				return nil, fmt.Errorf("failed to clone repo %s with ref %s (tried as branch and tag): %w", remote, ref, err)
 			}
 		} else {
-			return nil, "", fmt.Errorf("failed to clone repo %s with commit %s: %w", cloneURL, ref, err)
+			return nil, fmt.Errorf("failed to clone repo %s with commit %s: %w", remote, ref, err)
 		}
 	}
 
 	if len(ref) == 40 {
 		w, err := r.Worktree()
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to get worktree: %w", err)
+			return nil, fmt.Errorf("failed to get worktree: %w", err)
 		}
 		err = w.Checkout(&git.CheckoutOptions{
 			Hash: plumbing.NewHash(ref),
 		})
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to checkout commit %s: %w", ref, err)
+			return nil, fmt.Errorf("failed to checkout commit %s: %w", ref, err)
 		}
 	}
 
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, fmt.Errorf("failed to move repo %s into the cache: %w", remote, err)
+	}
+
+	return git.PlainOpen(dir)
+}
+
+// update fetches the ref of a location into a cached repository, and checks it
+// out. Commits never change, so repositories checked out at a commit are not
+// fetched.
+func (f *GitFetcher) update(r *git.Repository, loc *gitLocation) error {
+	ref := loc.ref
+	if len(ref) == 40 {
+		return nil
+	}
+
 	head, err := r.Head()
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get head for repo %s: %w", cloneURL, err)
+		return fmt.Errorf("failed to get head: %w", err)
 	}
-	hash := head.Hash().String()
 
-	w, err := r.Worktree()
+	// Branches are checked out with HEAD pointing at them, and tags with a
+	// detached HEAD. Locations without a ref use the branch that was the
+	// default branch when the repository was cloned.
+	var refSpec config.RefSpec
+	var target plumbing.ReferenceName
+	if head.Name().IsBranch() {
+		ref = head.Name().Short()
+		target = plumbing.NewRemoteReferenceName("origin", ref)
+		refSpec = config.RefSpec(fmt.Sprintf("+%s:%s", head.Name(), target))
+	} else {
+		target = plumbing.NewTagReferenceName(ref)
+		refSpec = config.RefSpec(fmt.Sprintf("+%s:%s", target, target))
+	}
+
+	auth, err := gitAuth(loc)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get worktree for repo %s: %w", cloneURL, err)
+		return err
 	}
 
-	file, err := w.Filesystem.Open(filePath)
+	err = r.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{refSpec},
+		Depth:      1,
+		Auth:       auth,
+		Tags:       git.NoTags,
+		Force:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to fetch ref %s: %w", ref, err)
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(target))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to open file '%s' in repo %s: %w", filePath, cloneURL, err)
+		return fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+	if *hash == head.Hash() {
+		return nil
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	w, err := r.Worktree()
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read file '%s' in repo %s: %w", filePath, cloneURL, err)
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: *hash, Mode: git.HardReset}); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w", hash, err)
 	}
+	return nil
+}
 
-	return data, hash, nil
+// isAuthError reports whether an error is caused by the remote rejecting the
+// credentials, rather than by the remote being unreachable.
+func isAuthError(err error) bool {
+	return errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed)
+}
+
+// gitAuth returns the credentials configured for the host of a location.
+// Repositories are cloned over HTTP with a token as the password, and over SSH
+// with a private key, or the keys in the SSH agent if there is none.
+func gitAuth(loc *gitLocation) (transport.AuthMethod, error) {
+	host := loc.url.Hostname()
+	key := func(name string) string {
+		return viper.GetString(fmt.Sprintf("git.auth.%s.%s", host, name))
+	}
+
+	switch loc.transport {
+	case "file":
+		return nil, nil
+	case "ssh":
+		user := loc.url.User.Username()
+		if user == "" {
+			user = "git"
+		}
+
+		// Hosts are checked against ~/.ssh/known_hosts, unless a file is
+		// configured.
+		var helper ssh.HostKeyCallbackHelper
+		if knownHosts := key("known_hosts"); knownHosts != "" {
+			callback, err := ssh.NewKnownHostsCallback(knownHosts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load known hosts for %s: %w", host, err)
+			}
+			helper.HostKeyCallback = callback
+		}
+
+		if keyFile := key("ssh_key"); keyFile != "" {
+			keys, err := ssh.NewPublicKeysFromFile(user, keyFile, key("ssh_key_passphrase"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load ssh key for %s: %w", host, err)
+			}
+			keys.HostKeyCallbackHelper = helper
+			return keys, nil
+		}
+
+		agent, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("no ssh key configured for %s, and failed to use the ssh agent: %w", host, err)
+		}
+		agent.HostKeyCallbackHelper = helper
+		return agent, nil
+	default:
+		token := key("token")
+		if token == "" {
+			return nil, nil
+		}
+		return &http.BasicAuth{
+			Username: key("username"),
+			Password: token,
+		}, nil
+	}
+}
+
+// cacheKey returns the name of the directory that a repository is cached in at
+// a ref.
+func cacheKey(remote, ref string) string {
+	name := strings.TrimSuffix(path.Base(strings.TrimSuffix(remote, "/")), ".git")
+	sum := sha256.Sum256([]byte(remote + "\x00" + ref))
+	return fmt.Sprintf("%s-%x", name, sum[:8])
 }
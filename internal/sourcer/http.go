@@ -0,0 +1,238 @@
+package sourcer
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultHTTPTimeout is the default timeout of a request for a source.
+	DefaultHTTPTimeout = 30 * time.Second
+	// DefaultHTTPRetries is the default number of times a failed request is
+	// retried.
+	DefaultHTTPRetries = 3
+	// maxHTTPBackoff is the longest time waited between retries.
+	maxHTTPBackoff = 30 * time.Second
+	// defaultSourceTimeout is the default of "source.timeout", the time the
+	// poller gives each source.
+	defaultSourceTimeout = time.Minute
+)
+
+// HTTPFetcher is an implementation of Fetcher that fetches content over HTTP.
+// Sources are requested conditionally, so sources that haven't changed since
+// the last poll aren't downloaded again.
+type HTTPFetcher struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	// budget is the time that a fetch, including its retries, can take.
+	budget time.Duration
+
+	mu        sync.Mutex
+	responses map[string]*httpResponse
+}
+
+// httpResponse is the last response for a URL.
+type httpResponse struct {
+	etag         string
+	lastModified string
+	contentType  string
+	body         []byte
+	state        string
+}
+
+// NewHTTPFetcher creates a new HTTPFetcher. The timeout of each request and
+// the number of retries are read from "http.timeout" and "http.retries".
+// Retries stop before "source.timeout" runs out, so that the poller doesn't
+// give up on a fetch that is still retrying.
+func NewHTTPFetcher() *HTTPFetcher {
+	timeout := viper.GetDuration("http.timeout")
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	retries := DefaultHTTPRetries
+	if viper.IsSet("http.retries") {
+		retries = max(viper.GetInt("http.retries"), 0)
+	}
+	sourceTimeout := viper.GetDuration("source.timeout")
+	if sourceTimeout <= 0 {
+		sourceTimeout = defaultSourceTimeout
+	}
+
+	return &HTTPFetcher{
+		client:    &http.Client{Timeout: timeout, CheckRedirect: redirectAuth},
+		retries:   retries,
+		backoff:   500 * time.Millisecond,
+		budget:    sourceTimeout * 9 / 10, // Leave time to parse the source.
+		responses: make(map[string]*httpResponse),
+	}
+}
+
+// Fetch fetches the content of a URL and returns it as a byte slice.
+func (f *HTTPFetcher) Fetch(url string) ([]byte, string, error) {
+	f.mu.Lock()
+	last := f.responses[url]
+	f.mu.Unlock()
+
+	deadline := time.Now().Add(f.budget)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = f.get(ctx, url, last)
+		if attempt == f.retries || !retryable(resp, err) {
+			break
+		}
+
+		wait := f.backoff << attempt
+		if resp != nil {
+			if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(after) * time.Second
+			}
+		}
+		wait = min(wait, maxHTTPBackoff)
+		// Give up with the last response if the retry would start too late.
+		if time.Until(deadline) < wait {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		time.Sleep(wait)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch url %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	// The source hasn't changed since the last time it was fetched.
+	if resp.StatusCode == http.StatusNotModified && last != nil {
+		return last.body, last.state, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch url %s: status code %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Prefer ETag, but fall back to Last-Modified.
+	var state string
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		state = etag
+	} else if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		state = lastModified
+	} else {
+		state = fmt.Sprintf("%x", sha256.Sum256(body))
+	}
+
+	f.mu.Lock()
+	f.responses[url] = &httpResponse{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		contentType:  resp.Header.Get("Content-Type"),
+		body:         body,
+		state:        state,
+	}
+	f.mu.Unlock()
+
+	return body, state, nil
+}
+
+// ContentType returns the media type of the content last fetched from a URL.
+func (f *HTTPFetcher) ContentType(url string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if last, ok := f.responses[url]; ok {
+		return last.contentType
+	}
+	return ""
+}
+
+// get requests a URL, conditionally on the last response if there is one.
+func (f *HTTPFetcher) get(ctx context.Context, rawURL string, last *httpResponse) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		if last.etag != "" {
+			req.Header.Set("If-None-Match", last.etag)
+		}
+		if last.lastModified != "" {
+			req.Header.Set("If-Modified-Since", last.lastModified)
+		}
+	}
+	httpAuth(req)
+
+	return f.client.Do(req)
+}
+
+// httpAuth adds the credentials configured for the host of a request to it.
+// Hosts can use a bearer token, a username and password, or any headers, such
+// as "PRIVATE-TOKEN" for GitLab.
+func httpAuth(req *http.Request) {
+	host := req.URL.Hostname()
+	key := func(name string) string {
+		return fmt.Sprintf("http.auth.%s.%s", host, name)
+	}
+
+	if token := viper.GetString(key("token")); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if username := viper.GetString(key("username")); username != "" {
+		req.SetBasicAuth(username, viper.GetString(key("password")))
+	}
+	for name, value := range authHeaders(host) {
+		req.Header.Set(name, value)
+	}
+}
+
+// authHeaders returns the headers configured for a host.
+func authHeaders(host string) map[string]string {
+	return viper.GetStringMapString(fmt.Sprintf("http.auth.%s.headers", host))
+}
+
+// redirectAuth applies the credentials of each host that a request is
+// redirected to. Redirects copy the headers of the first request, and only
+// drop "Authorization" and cookies when the host changes, so the headers
+// configured for the first host are dropped too.
+func redirectAuth(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if first := via[0].URL.Hostname(); req.URL.Hostname() != first {
+		req.Header.Del("Authorization")
+		for name := range authHeaders(first) {
+			req.Header.Del(name)
+		}
+	}
+	httpAuth(req)
+	return nil
+}
+
+// retryable reports whether a request should be retried. Requests are retried
+// after network errors, server errors and rate limiting.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var urlErr *url.Error
+		// Invalid URLs and unsupported protocols will never succeed.
+		return !errors.As(err, &urlErr) || urlErr.Op != "parse"
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,224 @@
+package sourcer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHTTPFetcher() *HTTPFetcher {
+	f := NewHTTPFetcher()
+	f.backoff = time.Millisecond
+	return f
+}
+
+func TestHTTPFetcher_Conditional(t *testing.T) {
+	var requests, downloads atomic.Int32
+	version := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		etag := `"` + version + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads.Add(1)
+		fmt.Fprintf(w, "calls: [] # %s", version)
+	}))
+	defer server.Close()
+
+	f := newTestHTTPFetcher()
+	data, state, err := f.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "calls: [] # v1", string(data))
+	assert.Equal(t, `"v1"`, state)
+
+	// Unchanged sources return the last response without downloading it.
+	data, state, err = f.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "calls: [] # v1", string(data))
+	assert.Equal(t, `"v1"`, state)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, int32(1), downloads.Load())
+
+	version = "v2"
+	data, state, err = f.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "calls: [] # v2", string(data))
+	assert.Equal(t, `"v2"`, state)
+	assert.Equal(t, int32(2), downloads.Load())
+}
+
+func TestHTTPFetcher_LastModified(t *testing.T) {
+	modified := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "calls.yaml", modified, strings.NewReader("calls: []"))
+	}))
+	defer server.Close()
+
+	f := newTestHTTPFetcher()
+	_, first, err := f.Fetch(server.URL)
+	require.NoError(t, err)
+	data, second, err := f.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "calls: []", string(data))
+	assert.Equal(t, "Wed, 01 Jan 2025 00:00:00 GMT", first)
+	assert.Equal(t, first, second)
+}
+
+func TestHTTPFetcher_Retries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if requests.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "calls: []")
+		case "/limited":
+			requests.Add(1)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			requests.Add(1)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := newTestHTTPFetcher()
+	data, _, err := f.Fetch(server.URL + "/flaky")
+	require.NoError(t, err)
+	assert.Equal(t, "calls: []", string(data))
+	assert.Equal(t, int32(3), requests.Load())
+
+	requests.Store(0)
+	f.retries = 1
+	_, _, err = f.Fetch(server.URL + "/limited")
+	assert.ErrorContains(t, err, "status code 429")
+	assert.Equal(t, int32(2), requests.Load())
+
+	// Client errors are not retried.
+	requests.Store(0)
+	_, _, err = f.Fetch(server.URL + "/missing")
+	assert.ErrorContains(t, err, "status code 404")
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestHTTPFetcher_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	viper.Set("http.timeout", "20ms")
+	viper.Set("http.retries", 0)
+	defer viper.Set("http.timeout", "")
+	defer viper.Set("http.retries", nil)
+
+	f := NewHTTPFetcher()
+	assert.Equal(t, 0, f.retries)
+	_, _, err := f.Fetch(server.URL)
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+}
+
+func TestHTTPFetcher_Budget(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+			return
+		}
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	viper.Set("source.timeout", "100ms")
+	defer viper.Set("source.timeout", nil)
+	f := newTestHTTPFetcher()
+	assert.Equal(t, 90*time.Millisecond, f.budget)
+
+	// Retries that would start after the source times out are not made.
+	start := time.Now()
+	_, _, err := f.Fetch(server.URL + "/unavailable")
+	assert.ErrorContains(t, err, "status code 503")
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	// Requests don't run past it either.
+	start = time.Now()
+	_, _, err = f.Fetch(server.URL + "/slow")
+	assert.ErrorContains(t, err, "context deadline exceeded")
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestHTTPFetcher_Auth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s", r.Header.Get("Authorization"), r.Header.Get("Private-Token"))
+	}))
+	defer server.Close()
+
+	f := newTestHTTPFetcher()
+	// The test server listens on 127.0.0.1, and other hosts get no credentials.
+	viper.Set("http.auth.localhost.token", "wrong-host")
+	defer viper.Set("http.auth.localhost.token", "")
+
+	data, _, err := f.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "|", string(data))
+
+	viper.Set("http.auth.127.0.0.1.token", "secret")
+	data, _, err = f.Fetch(server.URL + "/bearer")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer secret|", string(data))
+
+	viper.Set("http.auth.127.0.0.1.token", "")
+	viper.Set("http.auth.127.0.0.1.username", "ruf")
+	viper.Set("http.auth.127.0.0.1.password", "hunter2")
+	viper.Set("http.auth.127.0.0.1.headers", map[string]string{"PRIVATE-TOKEN": "gitlab-token"})
+	defer viper.Set("http.auth.127.0.0.1.username", "")
+	defer viper.Set("http.auth.127.0.0.1.headers", nil)
+	data, _, err = f.Fetch(server.URL + "/basic")
+	require.NoError(t, err)
+	assert.Equal(t, "Basic cnVmOmh1bnRlcjI=|gitlab-token", string(data))
+}
+
+func TestHTTPFetcher_AuthRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			// Redirect to the same server under another host name.
+			http.Redirect(w, r, strings.Replace(r.URL.Query().Get("to"), "127.0.0.1", "localhost", 1), http.StatusFound)
+			return
+		}
+		fmt.Fprintf(w, "%s|%s", r.Header.Get("Authorization"), r.Header.Get("Private-Token"))
+	}))
+	defer server.Close()
+
+	viper.Set("http.auth.127.0.0.1.headers", map[string]string{"PRIVATE-TOKEN": "gitlab-token"})
+	defer viper.Set("http.auth.127.0.0.1.headers", nil)
+	f := newTestHTTPFetcher()
+
+	// The headers configured for the first host aren't sent to another host.
+	data, _, err := f.Fetch(server.URL + "/redirect?to=" + server.URL + "/calls.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "|", string(data))
+
+	// The other host gets its own credentials.
+	viper.Set("http.auth.localhost.token", "other-token")
+	defer viper.Set("http.auth.localhost.token", "")
+	data, _, err = f.Fetch(server.URL + "/redirect?to=" + server.URL + "/other.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer other-token|", string(data))
+}
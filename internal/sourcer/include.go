@@ -0,0 +1,146 @@
+package sourcer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MaxIncludeDepth is how deeply includes can be nested.
+const MaxIncludeDepth = 8
+
+// includer loads a source and the sources it includes.
+type includer struct {
+	sourcer *sourcer
+	// seen is the URLs that have been loaded, so a file included by more than
+	// one source is only added once.
+	seen map[string]bool
+	// states is the state of each URL that has been loaded.
+	states []string
+	// ids is the URL of the source that defines each call ID, as calls are
+	// told apart by their ID within a campaign.
+	ids map[string]string
+}
+
+// load loads the source at a URL and adds the calls and events of the sources
+// it includes to it. Stack is the URLs of the sources that include it.
+func (in *includer) load(rawURL string, stack []string) (*Source, error) {
+	stack = append(stack, rawURL)
+
+	source, state, err := in.sourcer.parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	in.seen[rawURL] = true
+	in.states = append(in.states, rawURL+"\x00"+state)
+
+	for _, call := range source.Calls {
+		if call.ID == "" {
+			continue
+		}
+		if other, ok := in.ids[call.ID]; ok {
+			if other == rawURL {
+				// Files without includes have always allowed this, so it is
+				// only a warning.
+				source.Warnings = append(source.Warnings, Diagnostic{
+					URL:     rawURL,
+					Message: fmt.Sprintf("duplicate call id %q", call.ID),
+				})
+				continue
+			}
+			return nil, fmt.Errorf("duplicate call id %q in %s and %s", call.ID, other, rawURL)
+		}
+		in.ids[call.ID] = rawURL
+	}
+
+	for _, include := range source.Include {
+		ref, err := resolveInclude(rawURL, include)
+		if err != nil {
+			return nil, err
+		}
+		files, err := in.sourcer.List(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list include %s in %s: %w", ref, rawURL, err)
+		}
+
+		for _, file := range files {
+			if slices.Contains(stack, file) {
+				return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack, file), " -> "))
+			}
+			if in.seen[file] {
+				continue
+			}
+			if len(stack) > MaxIncludeDepth {
+				return nil, fmt.Errorf("includes are nested more than %d deep: %s", MaxIncludeDepth, strings.Join(append(stack, file), " -> "))
+			}
+
+			included, err := in.load(file, stack)
+			if err != nil {
+				return nil, fmt.Errorf("failed to include %s in %s: %w", file, rawURL, err)
+			}
+			source.Calls = append(source.Calls, included.Calls...)
+			source.Events = append(source.Events, included.Events...)
+			source.Warnings = append(source.Warnings, included.Warnings...)
+		}
+	}
+
+	return source, nil
+}
+
+// state returns the state of the loaded sources, which changes when any of
+// them changes. Sources without includes keep the state of their fetcher.
+func (in *includer) state() string {
+	if len(in.states) == 1 {
+		_, state, _ := strings.Cut(in.states[0], "\x00")
+		return state
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(in.states, "\n"))))
+}
+
+// localSchemes are the schemes of sources on the worker's host, which remote
+// sources can't include.
+var localSchemes = map[string]bool{"file": true, "git+file": true}
+
+// IncludedBy returns the URL of a source, other than the file itself, that
+// includes a file, given the URLs of the files that each source includes.
+func IncludedBy(file string, includes map[string][]string) (string, bool) {
+	urls := make([]string, 0, len(includes))
+	for url := range includes {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	for _, url := range urls {
+		if url != file && slices.Contains(includes[url], file) {
+			return url, true
+		}
+	}
+	return "", false
+}
+
+// resolveInclude resolves the URL of an include against the URL of the source
+// that includes it. Relative includes in git sources keep the ref of the
+// source, so both are read from the same commit. Remote sources can't include
+// local files, so that whoever controls them can't read the worker's files.
+func resolveInclude(baseURL, include string) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url %s: %w", baseURL, err)
+	}
+	ref, err := url.Parse(include)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse include %s in %s: %w", include, baseURL, err)
+	}
+
+	u := base.ResolveReference(ref)
+	if localSchemes[u.Scheme] && !localSchemes[base.Scheme] {
+		return "", fmt.Errorf("invalid include %s in %s: remote sources can't include local files", include, baseURL)
+	}
+	if !ref.IsAbs() && ref.RawQuery == "" && strings.HasPrefix(base.Scheme, "git+") {
+		u.RawQuery = base.RawQuery
+	}
+	return u.String(), nil
+}
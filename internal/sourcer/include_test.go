@@ -0,0 +1,181 @@
+package sourcer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files with the given contents into a directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func newFileSourcer() Sourcer {
+	fetcher := NewCompositeFetcher()
+	fetcher.AddFetcher("file", NewFileFetcher())
+	return NewSourcer(fetcher, NewParsers(false))
+}
+
+func TestSourcer_Include(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"release.yaml": `
+campaign:
+  id: release
+include:
+  - shared/events.yaml
+  - reminders/
+calls:
+  - id: announce
+`,
+		"shared/events.yaml": `
+events:
+  - sequence: launch
+    start_time: 2025-02-01T09:00:00Z
+`,
+		"reminders/day-before.yaml": `
+campaign:
+  id: ignored
+include:
+  - ../shared/events.yaml
+calls:
+  - id: day-before
+`,
+		"reminders/hour-before.json": `{"calls": [{"id": "hour-before"}]}`,
+	})
+
+	s := newFileSourcer()
+	source, state, err := s.Source("file://" + dir + "/release.yaml")
+	require.NoError(t, err)
+
+	var ids []string
+	for _, call := range source.Calls {
+		ids = append(ids, call.ID)
+		assert.Equal(t, "release", call.Campaign.ID)
+	}
+	assert.Equal(t, []string{"announce", "day-before", "hour-before"}, ids)
+	assert.Equal(t, []string{
+		"file://" + dir + "/reminders/day-before.yaml",
+		"file://" + dir + "/reminders/hour-before.json",
+		"file://" + dir + "/shared/events.yaml",
+	}, source.Included)
+	// Files included more than once are only added once.
+	require.Len(t, source.Events, 1)
+	assert.Equal(t, "launch", source.Events[0].Sequence)
+
+	// The state changes when an included file changes.
+	_, unchanged, err := s.Source("file://" + dir + "/release.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, state, unchanged)
+
+	writeFiles(t, dir, map[string]string{"shared/events.yaml": "events: []\n"})
+	_, changed, err := s.Source("file://" + dir + "/release.yaml")
+	require.NoError(t, err)
+	assert.NotEqual(t, state, changed)
+}
+
+func TestSourcer_IncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml":           "include: [b.yaml]\n",
+		"b.yaml":           "include: [c.yaml]\n",
+		"c.yaml":           "include: [a.yaml]\n",
+		"missing.yaml":     "include: [nothing.yaml]\n",
+		"reminders.yaml":   "include: [reminders/]\ncalls: [{id: announce}]\n",
+		"reminders/a.yaml": "calls: [{id: reminder}]\n",
+		"reminders/b.yaml": "calls: [{id: reminder}]\n",
+		"twice.yaml":       "calls: [{id: reminder}, {id: reminder}]\n",
+	}
+	// A chain of includes that is too deep.
+	for i := 0; i <= MaxIncludeDepth; i++ {
+		files[fmt.Sprintf("deep-%d.yaml", i)] = fmt.Sprintf("include: [deep-%d.yaml]\n", i+1)
+	}
+	files[fmt.Sprintf("deep-%d.yaml", MaxIncludeDepth+1)] = "calls: []\n"
+	writeFiles(t, dir, files)
+
+	s := newFileSourcer()
+	url := func(name string) string { return "file://" + dir + "/" + name }
+
+	_, _, err := s.Source(url("a.yaml"))
+	assert.ErrorContains(t, err, "include cycle: "+strings.Join([]string{url("a.yaml"), url("b.yaml"), url("c.yaml"), url("a.yaml")}, " -> "))
+
+	_, _, err = s.Source(url("missing.yaml"))
+	assert.ErrorContains(t, err, "failed to include "+url("nothing.yaml")+" in "+url("missing.yaml"))
+
+	// Calls are told apart by their ID, so IDs must be unique across includes.
+	_, _, err = s.Source(url("reminders.yaml"))
+	assert.ErrorContains(t, err, fmt.Sprintf("duplicate call id %q in %s and %s", "reminder", url("reminders/a.yaml"), url("reminders/b.yaml")))
+	// Within a file, a duplicate ID is only a warning.
+	source, _, err := s.Source(url("twice.yaml"))
+	if assert.NoError(t, err) {
+		assert.Len(t, source.Calls, 2)
+		assert.Equal(t, Diagnostics{{URL: url("twice.yaml"), Message: `duplicate call id "reminder"`}}, source.Warnings)
+	}
+
+	_, _, err = s.Source(url("deep-0.yaml"))
+	assert.ErrorContains(t, err, fmt.Sprintf("includes are nested more than %d deep", MaxIncludeDepth))
+
+	// Sources at the depth limit are fine.
+	_, _, err = s.Source(url("deep-1.yaml"))
+	assert.NoError(t, err)
+}
+
+func TestResolveInclude(t *testing.T) {
+	testCases := []struct {
+		base     string
+		include  string
+		expected string
+	}{
+		{"file:///etc/ruf/release.yaml", "shared/events.yaml", "file:///etc/ruf/shared/events.yaml"},
+		{"file:///etc/ruf/release.yaml", "/srv/events.yaml", "file:///srv/events.yaml"},
+		{"https://example.com/calls/release.yaml?token=abc", "../events.yaml", "https://example.com/events.yaml"},
+		{"https://example.com/calls/release.yaml", "git+ssh://git.example.com/repo.git//events.yaml", "git+ssh://git.example.com/repo.git//events.yaml"},
+		{"git://github.com/user/repo/tree/main/calls/release.yaml", "events.yaml", "git://github.com/user/repo/tree/main/calls/events.yaml"},
+		{"git+ssh://git.example.com/repo.git//calls/release.yaml?ref=v1", "events.yaml", "git+ssh://git.example.com/repo.git//calls/events.yaml?ref=v1"},
+		{"git+ssh://git.example.com/repo.git//calls/release.yaml?ref=v1", "events.yaml?ref=main", "git+ssh://git.example.com/repo.git//calls/events.yaml?ref=main"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.base+" "+tc.include, func(t *testing.T) {
+			u, err := resolveInclude(tc.base, tc.include)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, u)
+		})
+	}
+}
+
+func TestResolveInclude_Local(t *testing.T) {
+	// Remote sources can't include files from the worker's host.
+	for _, base := range []string{"https://example.com/calls/release.yaml", "s3://bucket/release.yaml", "git+ssh://git.example.com/repo.git//release.yaml"} {
+		for _, include := range []string{"file:///etc/passwd", "git+file:///srv/repo.git//calls.yaml"} {
+			_, err := resolveInclude(base, include)
+			assert.ErrorContains(t, err, "remote sources can't include local files", base+" "+include)
+		}
+	}
+
+	_, err := resolveInclude("git+file:///srv/repo.git//release.yaml", "file:///etc/ruf/events.yaml")
+	assert.NoError(t, err)
+}
+
+func TestIncludedBy(t *testing.T) {
+	includes := map[string][]string{
+		"file:///a.yaml": {"file:///b.yaml"},
+		"file:///b.yaml": nil,
+	}
+	parent, ok := IncludedBy("file:///b.yaml", includes)
+	assert.True(t, ok)
+	assert.Equal(t, "file:///a.yaml", parent)
+	_, ok = IncludedBy("file:///a.yaml", includes)
+	assert.False(t, ok)
+}
@@ -0,0 +1,48 @@
+package sourcer
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Lister is implemented by fetchers that can expand a URL that refers to more
+// than one source file, such as a directory or a glob, into the URLs of each
+// file.
+type Lister interface {
+	List(url string) ([]string, error)
+}
+
+// List expands a URL into the URLs of the source files it refers to. URLs that
+// s can't expand are returned as they are.
+func List(s Sourcer, url string) ([]string, error) {
+	l, ok := s.(Lister)
+	if !ok {
+		return []string{url}, nil
+	}
+	return l.List(url)
+}
+
+// isSourceFile reports whether a file found in a directory or by a glob is a
+// source file in one of the supported formats. Hidden files, such as editor backups, are skipped.
+func isSourceFile(name string) bool {
+	base := path.Base(name)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	_, ok := formatExtensions[strings.ToLower(path.Ext(base))]
+	return ok
+}
+
+// hasMeta reports whether a path contains any glob characters.
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
+
+// withPath returns a copy of a URL with a different path.
+func withPath(u *url.URL, p string) string {
+	c := *u
+	c.Path = p
+	c.RawPath = ""
+	return c.String()
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package sourcer
+
+// lockFile is a no-op on platforms without flock. Repositories are still
+// locked against concurrent use within a process.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
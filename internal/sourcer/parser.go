@@ -0,0 +1,316 @@
+package sourcer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of a source file.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// formatExtensions maps file extensions to the format of the files.
+var formatExtensions = map[string]Format{
+	".yaml": FormatYAML,
+	".yml":  FormatYAML,
+	".json": FormatJSON,
+	".toml": FormatTOML,
+}
+
+// formatMediaTypes maps media types to the format of the content.
+var formatMediaTypes = map[string]Format{
+	"application/yaml":   FormatYAML,
+	"application/x-yaml": FormatYAML,
+	"text/yaml":          FormatYAML,
+	"text/x-yaml":        FormatYAML,
+	"application/json":   FormatJSON,
+	"text/json":          FormatJSON,
+	"application/toml":   FormatTOML,
+	"text/toml":          FormatTOML,
+	"text/x-toml":        FormatTOML,
+}
+
+// Parser defines the interface for parsing content into a list of calls.
+type Parser interface {
+	Parse(url string, data []byte) (*Source, error)
+}
+
+// ContentTyper is implemented by fetchers that know the media type of the
+// content they fetched from a URL, such as HTTPFetcher.
+type ContentTyper interface {
+	ContentType(url string) string
+}
+
+// ContentTypeParser is implemented by parsers that use the media type of the
+// content to choose how to parse it.
+type ContentTypeParser interface {
+	ParseContentType(url, contentType string, data []byte) (*Source, error)
+}
+
+// DetectFormat returns the format of a source. It is, in order of preference:
+//
+//   - the format named in the URL's fragment, as in "https://example.com/calls#format=json"
+//   - the format of the URL's file extension
+//   - the format of the content type, if it is known
+//   - YAML
+func DetectFormat(rawURL, contentType string) (Format, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+	}
+
+	if hint, ok := strings.CutPrefix(u.Fragment, "format="); ok {
+		format := Format(strings.ToLower(hint))
+		switch format {
+		case FormatYAML, FormatJSON, FormatTOML:
+			return format, nil
+		}
+		return "", fmt.Errorf("unsupported format '%s' in url %s", hint, rawURL)
+	}
+
+	if format, ok := formatExtensions[strings.ToLower(path.Ext(u.Path))]; ok {
+		return format, nil
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if format, ok := formatMediaTypes[mediaType]; ok {
+			return format, nil
+		}
+		// Structured syntax suffixes, such as "application/vnd.ruf+json".
+		if _, suffix, ok := strings.Cut(mediaType, "+"); ok {
+			if format, ok := formatExtensions["."+suffix]; ok {
+				return format, nil
+			}
+		}
+	}
+
+	return FormatYAML, nil
+}
+
+// Parsers is a Parser that parses each source with the parser for its format.
+type Parsers struct {
+	parsers map[Format]Parser
+}
+
+// NewParsers creates a new Parsers, with parsers for YAML, JSON and TOML. In
+// strict mode, sources with unknown fields are rejected. Otherwise, they are
+// parsed, and the unknown fields are listed in the source's warnings.
+func NewParsers(strict bool) *Parsers {
+	p := &Parsers{
+		parsers: make(map[Format]Parser),
+	}
+	p.AddParser(FormatYAML, &YAMLParser{strict: strict})
+	p.AddParser(FormatJSON, &JSONParser{strict: strict})
+	p.AddParser(FormatTOML, &TOMLParser{strict: strict})
+	return p
+}
+
+// AddParser adds a new parser for a given format.
+func (p *Parsers) AddParser(format Format, parser Parser) {
+	p.parsers[format] = parser
+}
+
+// Parse parses a source with the parser for the format of its URL.
+func (p *Parsers) Parse(url string, data []byte) (*Source, error) {
+	return p.ParseContentType(url, "", data)
+}
+
+// ParseContentType parses a source with the parser for the format of its URL
+// or content type.
+func (p *Parsers) ParseContentType(url, contentType string, data []byte) (*Source, error) {
+	format, err := DetectFormat(url, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	parser, ok := p.parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	return parser.Parse(url, data)
+}
+
+// sourceType is the type that sources are decoded into.
+var sourceType = reflect.TypeOf(Source{})
+
+// YAMLParser is an implementation of Parser that parses YAML content.
+type YAMLParser struct {
+	strict bool
+}
+
+// NewYAMLParser creates a new YAMLParser.
+func NewYAMLParser() *YAMLParser {
+	return &YAMLParser{}
+}
+
+// Parse parses a YAML byte slice and returns a list of calls.
+func (p *YAMLParser) Parse(rawURL string, data []byte) (*Source, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, yamlDiagnostics(rawURL, err)
+	}
+
+	var s Source
+	if node.Kind == 0 {
+		// The file is empty.
+		return finishSource(rawURL, &s, nil, p.strict)
+	}
+	if err := node.Decode(&s); err != nil {
+		return nil, yamlDiagnostics(rawURL, err)
+	}
+
+	return finishSource(rawURL, &s, checkNode(rawURL, &node, sourceType), p.strict)
+}
+
+// JSONParser is an implementation of Parser that parses JSON content.
+type JSONParser struct {
+	strict bool
+}
+
+// NewJSONParser creates a new JSONParser.
+func NewJSONParser() *JSONParser {
+	return &JSONParser{}
+}
+
+// Parse parses a JSON byte slice and returns a list of calls.
+func (p *JSONParser) Parse(rawURL string, data []byte) (*Source, error) {
+	var s Source
+	if err := json.Unmarshal(data, &s); err != nil {
+		diag := Diagnostic{URL: rawURL, Message: strings.TrimPrefix(err.Error(), "json: ")}
+		// The offsets of errors are after the byte that caused them.
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			diag.Line, diag.Column = position(data, syntaxErr.Offset-1)
+		case errors.As(err, &typeErr):
+			diag.Line, diag.Column = position(data, typeErr.Offset-1)
+		}
+		return nil, Diagnostics{diag}
+	}
+
+	// JSON is YAML, so the positions of unknown fields are found in the
+	// YAML node tree.
+	var warnings Diagnostics
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err == nil {
+		warnings = checkNode(rawURL, &node, sourceType)
+	}
+
+	return finishSource(rawURL, &s, warnings, p.strict)
+}
+
+// TOMLParser is an implementation of Parser that parses TOML content.
+type TOMLParser struct {
+	strict bool
+}
+
+// NewTOMLParser creates a new TOMLParser.
+func NewTOMLParser() *TOMLParser {
+	return &TOMLParser{}
+}
+
+// Parse parses a TOML byte slice and returns a list of calls.
+func (p *TOMLParser) Parse(rawURL string, data []byte) (*Source, error) {
+	var s Source
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var warnings Diagnostics
+	err := dec.Decode(&s)
+	var decodeErr *toml.DecodeError
+	var missingErr *toml.StrictMissingError
+	switch {
+	case errors.As(err, &missingErr):
+		// The rest of the source is still decoded.
+		for _, e := range missingErr.Errors {
+			line, column := e.Position()
+			keys := e.Key()
+			msg := fmt.Sprintf("unknown field %q", keys[len(keys)-1])
+			if t, ok := fieldAt(sourceType, keys[:len(keys)-1]); ok && t.Kind() == reflect.Struct {
+				msg = unknownField(keys[len(keys)-1], t)
+			}
+			warnings = append(warnings, Diagnostic{URL: rawURL, Line: line, Column: column, Message: msg})
+		}
+	case errors.As(err, &decodeErr):
+		line, column := decodeErr.Position()
+		return nil, Diagnostics{{URL: rawURL, Line: line, Column: column, Message: strings.TrimPrefix(decodeErr.Error(), "toml: ")}}
+	case err != nil:
+		return nil, Diagnostics{{URL: rawURL, Message: strings.TrimPrefix(err.Error(), "toml: ")}}
+	}
+
+	return finishSource(rawURL, &s, warnings, p.strict)
+}
+
+// finishSource rejects a parsed source with warnings in strict mode, or sets
+// its warnings and defaults otherwise.
+func finishSource(rawURL string, s *Source, warnings Diagnostics, strict bool) (*Source, error) {
+	if len(warnings) > 0 {
+		if strict {
+			return nil, warnings
+		}
+		s.Warnings = warnings
+	}
+
+	fillSource(rawURL, s)
+	return s, nil
+}
+
+// fillSource sets the defaults of a parsed source, whatever its format.
+func fillSource(rawURL string, s *Source) {
+	fillCampaign(rawURL, s)
+
+	// Add the campaign and the defaults to each call.
+	for i := range s.Calls {
+		s.Calls[i].Campaign = s.Campaign
+		s.Defaults.apply(&s.Calls[i])
+	}
+}
+
+func fillCampaign(rawURL string, s *Source) error {
+	// If the campaign isn't specified, we'll derive it from the filename.
+	if s.Campaign.ID == "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+		}
+
+		// my.campaign.yaml -> my-campaign
+		name := u.Path[strings.LastIndex(u.Path, "/")+1:]
+		base := name
+		if _, ok := formatExtensions[strings.ToLower(path.Ext(base))]; ok {
+			base = strings.TrimSuffix(base, path.Ext(base))
+		}
+		s.Campaign.ID = strings.ReplaceAll(base, ".", "-")
+
+		// Older versions only stripped ".yaml", so "calls.yml" was
+		// "calls-yml".
+		if legacy := strings.ReplaceAll(strings.TrimSuffix(name, ".yaml"), ".", "-"); legacy != s.Campaign.ID {
+			s.Campaign.LegacyID = legacy
+		}
+	}
+	if s.Campaign.Name == "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+		}
+		s.Campaign.Name = u.Path
+	}
+	return nil
+}
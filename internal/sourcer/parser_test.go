@@ -0,0 +1,159 @@
+package sourcer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsers_Fixtures(t *testing.T) {
+	expected := &Source{
+		Campaign: model.Campaign{ID: "campaign", Name: "Release announcements"},
+		Calls: []model.Call{
+			{
+				ID:      "release",
+				Author:  "releases@example.com",
+				Subject: "v1.0.0 is out",
+				Content: "Read the release notes.",
+				Destinations: []model.Destination{
+					{Type: "slack", To: []string{"#general", "#releases"}},
+					{Type: "ntfy", To: []string{"releases"}, Priority: 4, Tags: []string{"tada"}},
+				},
+				Triggers: []model.Trigger{
+					{ScheduledAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)},
+					{Sequence: "launch", Delta: "-24h"},
+				},
+				Campaign: model.Campaign{ID: "campaign", Name: "Release announcements"},
+			},
+			{
+				ID:           "standup",
+				Content:      "Standup in five minutes.",
+				Destinations: []model.Destination{{Type: "email", To: []string{"team@example.com"}}},
+				Triggers:     []model.Trigger{{Cron: "55 9 * * 1-5"}},
+				Campaign:     model.Campaign{ID: "campaign", Name: "Release announcements"},
+			},
+		},
+		Events: []model.Event{
+			{
+				Sequence:  "launch",
+				Title:     "Launch",
+				StartTime: time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+				Duration:  "1h",
+				Invite:    true,
+			},
+		},
+	}
+
+	parser := NewParsers(false)
+	for _, name := range []string{"campaign.yaml", "campaign.json", "campaign.toml"} {
+		t.Run(name, func(t *testing.T) {
+			path, err := filepath.Abs(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			source, err := parser.Parse("file://"+path, data)
+			require.NoError(t, err)
+
+			// Only the YAML file had the same campaign ID in older versions.
+			if name != "campaign.yaml" {
+				assert.Equal(t, strings.ReplaceAll(name, ".", "-"), source.Campaign.LegacyID)
+				source.Campaign.LegacyID = ""
+				for i := range source.Calls {
+					source.Calls[i].Campaign.LegacyID = ""
+				}
+			}
+			assert.Equal(t, expected, source)
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	testCases := []struct {
+		url         string
+		contentType string
+		expected    Format
+		err         string
+	}{
+		{url: "file:///calls.yaml", expected: FormatYAML},
+		{url: "file:///calls.YML", expected: FormatYAML},
+		{url: "file:///calls.json", expected: FormatJSON},
+		{url: "git+ssh://example.com/repo.git//calls.toml?ref=main", expected: FormatTOML},
+		{url: "https://example.com/calls", contentType: "application/json; charset=utf-8", expected: FormatJSON},
+		{url: "https://example.com/calls", contentType: "application/vnd.example+toml", expected: FormatTOML},
+		{url: "https://example.com/calls", contentType: "text/plain", expected: FormatYAML},
+		{url: "https://example.com/calls.yaml", contentType: "application/json", expected: FormatYAML},
+		{url: "https://example.com/calls.yaml#format=json", expected: FormatJSON},
+		{url: "https://example.com/calls#format=ini", err: "unsupported format 'ini'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url+" "+tc.contentType, func(t *testing.T) {
+			format, err := DetectFormat(tc.url, tc.contentType)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, format)
+		})
+	}
+}
+
+func TestFillCampaign(t *testing.T) {
+	for url, expected := range map[string]string{
+		"file:///announcements.yaml":        "announcements",
+		"file:///announcements.yml":         "announcements",
+		"file:///announcements.json":        "announcements",
+		"file:///team.announcements.toml":   "team-announcements",
+		"https://example.com/announcements": "announcements",
+		"https://example.com/calls.txt":     "calls-txt",
+	} {
+		var s Source
+		require.NoError(t, fillCampaign(url, &s))
+		assert.Equal(t, expected, s.Campaign.ID, url)
+	}
+}
+
+func TestFillCampaign_LegacyID(t *testing.T) {
+	for url, expected := range map[string]string{
+		"file:///announcements.yaml":      "",
+		"file:///announcements.yml":       "announcements-yml",
+		"file:///team.announcements.json": "team-announcements-json",
+		"https://example.com/calls.txt":   "",
+	} {
+		var s Source
+		require.NoError(t, fillCampaign(url, &s))
+		assert.Equal(t, expected, s.Campaign.LegacyID, url)
+	}
+
+	// Campaigns with an ID set never had a derived one.
+	s := Source{Campaign: model.Campaign{ID: "announcements"}}
+	require.NoError(t, fillCampaign("file:///announcements.yml", &s))
+	assert.Empty(t, s.Campaign.LegacyID)
+}
+
+func TestSourcer_ContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"calls": [{"id": "json-call"}]}`))
+	}))
+	defer server.Close()
+
+	fetcher := NewCompositeFetcher()
+	fetcher.AddFetcher("http", NewHTTPFetcher())
+	s := NewSourcer(fetcher, NewParsers(false))
+
+	source, _, err := s.Source(server.URL + "/calls")
+	require.NoError(t, err)
+	require.Len(t, source.Calls, 1)
+	assert.Equal(t, "json-call", source.Calls[0].ID)
+	assert.Equal(t, "calls", source.Campaign.ID)
+}
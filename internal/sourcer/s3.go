@@ -0,0 +1,306 @@
+package sourcer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config is the configuration of an S3Fetcher.
+type S3Config struct {
+	// Endpoint is the URL of the S3 API, for S3-compatible object storage. It
+	// defaults to the AWS endpoint for the region.
+	Endpoint string
+	// Region is the region that requests are signed for. It defaults to
+	// $AWS_REGION, or "us-east-1".
+	Region string
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials that
+	// requests are signed with. They default to $AWS_ACCESS_KEY_ID,
+	// $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle puts the bucket in the path of requests, rather than in the
+	// host, as most S3-compatible object storage requires.
+	PathStyle bool
+}
+
+// S3Fetcher is an implementation of Fetcher that fetches objects from
+// S3-compatible object storage, with URLs in the format s3://<bucket>/<key>.
+// The ETag of the object is used as its state, and objects are only
+// downloaded when it changes.
+type S3Fetcher struct {
+	cfg    S3Config
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	objects map[string]*s3Object
+}
+
+// s3Object is the last version of an object that was downloaded.
+type s3Object struct {
+	etag        string
+	contentType string
+	body        []byte
+}
+
+// NewS3Fetcher creates a new S3Fetcher.
+func NewS3Fetcher(cfg S3Config) *S3Fetcher {
+	if cfg.Region == "" {
+		cfg.Region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.AccessKeyID == "" && cfg.SecretAccessKey == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+
+	return &S3Fetcher{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: DefaultHTTPTimeout},
+		now:     time.Now,
+		objects: make(map[string]*s3Object),
+	}
+}
+
+// Fetch fetches an object and returns its content and ETag.
+func (f *S3Fetcher) Fetch(rawURL string) ([]byte, string, error) {
+	objectURL, err := f.objectURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Check the ETag first, so unchanged objects aren't downloaded again.
+	resp, err := f.do(http.MethodHead, objectURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch url %s: %w", rawURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch url %s: status code %d", rawURL, resp.StatusCode)
+	}
+
+	etag := resp.Header.Get("ETag")
+	f.mu.Lock()
+	last := f.objects[rawURL]
+	f.mu.Unlock()
+	if last != nil && etag != "" && last.etag == etag {
+		return last.body, last.etag, nil
+	}
+
+	resp, err = f.do(http.MethodGet, objectURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch url %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch url %s: %s", rawURL, s3Error(resp))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read object %s: %w", rawURL, err)
+	}
+
+	// The object may have changed between the requests.
+	etag = resp.Header.Get("ETag")
+	if etag == "" {
+		etag = fmt.Sprintf("%x", sha256.Sum256(body))
+	}
+
+	f.mu.Lock()
+	f.objects[rawURL] = &s3Object{etag: etag, contentType: resp.Header.Get("Content-Type"), body: body}
+	f.mu.Unlock()
+
+	return body, etag, nil
+}
+
+// ContentType returns the content type of the object last fetched from a URL.
+func (f *S3Fetcher) ContentType(rawURL string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if last, ok := f.objects[rawURL]; ok {
+		return last.contentType
+	}
+	return ""
+}
+
+// objectURL returns the URL of the object in an s3:// URL.
+func (f *S3Fetcher) objectURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid s3 url %s: expected s3://<bucket>/<key>", rawURL)
+	}
+
+	endpoint, err := url.Parse(f.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint %s: %w", f.cfg.Endpoint, err)
+	}
+
+	objectURL := *endpoint
+	if f.cfg.PathStyle {
+		objectURL.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + bucket + "/" + key
+	} else {
+		objectURL.Host = bucket + "." + endpoint.Host
+		objectURL.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + key
+	}
+	objectURL.RawPath = uriEncode(objectURL.Path, false)
+	return &objectURL, nil
+}
+
+// do sends a signed request for an object.
+func (f *S3Fetcher) do(method string, objectURL *url.URL) (*http.Response, error) {
+	req, err := http.NewRequest(method, objectURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.cfg.AccessKeyID != "" {
+		signV4(req, f.cfg, f.now())
+	}
+	return f.client.Do(req)
+}
+
+// signV4 signs a request with AWS Signature Version 4, as described in
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html.
+// The host and every header already on the request are signed.
+func signV4(req *http.Request, cfg S3Config, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, cfg.Region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + cfg.SecretAccessKey)
+	for _, part := range []string{date, cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery returns the query parameters sorted and encoded for signing.
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes a string as AWS signatures expect, escaping every byte
+// other than unreserved characters, and slashes if encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Error returns the error in a response from the S3 API.
+func s3Error(resp *http.Response) string {
+	var apiErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr); err != nil || apiErr.Code == "" {
+		return fmt.Sprintf("status code %d", resp.StatusCode)
+	}
+	return fmt.Sprintf("status code %d: %s: %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+}
+
+// firstEnv returns the value of the first environment variable that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
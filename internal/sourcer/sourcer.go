@@ -3,21 +3,35 @@ package sourcer
 import (
 	"crypto/sha256"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"os"
-	"strings"
+	"path/filepath"
+	"sort"
 
 	"github.com/andrewhowdencom/ruf/internal/model"
-	"gopkg.in/yaml.v3"
 )
 
 // Source represents a source file.
 type Source struct {
-	Campaign model.Campaign `json:"campaign" yaml:"campaign"`
-	Calls    []model.Call   `json:"calls" yaml:"calls"`
-	Events   []model.Event  `json:"events" yaml:"events"`
+	// URL is the URL that the source was read from.
+	URL string `json:"-" yaml:"-" toml:"-"`
+
+	Campaign model.Campaign `json:"campaign" yaml:"campaign" toml:"campaign"`
+	Calls    []model.Call   `json:"calls" yaml:"calls" toml:"calls"`
+	Events   []model.Event  `json:"events" yaml:"events" toml:"events"`
+	// Defaults are merged into each call in the source file.
+	Defaults Defaults `json:"defaults,omitempty" yaml:"defaults,omitempty" toml:"defaults"`
+	// Include lists the URLs of other sources whose calls and events are
+	// added to this one. Relative URLs are resolved against this source's URL.
+	Include []string `json:"include,omitempty" yaml:"include,omitempty" toml:"include"`
+
+	// Included is the URLs of the files that the source includes, directly
+	// or through other includes.
+	Included []string `json:"-" yaml:"-" toml:"-"`
+
+	// Warnings are the problems found when the source was parsed that didn't
+	// stop it from being used, such as unknown fields.
+	Warnings Diagnostics `json:"-" yaml:"-" toml:"-"`
 }
 
 // Fetcher defines the interface for fetching content from a URL.
@@ -57,46 +71,52 @@ func (f *CompositeFetcher) Fetch(rawURL string) ([]byte, string, error) {
 	return fetcher.Fetch(rawURL)
 }
 
-// HTTPFetcher is an implementation of Fetcher that fetches content over HTTP.
-type HTTPFetcher struct {
-	client *http.Client
-}
+// List expands a URL with the fetcher for its scheme, if that fetcher is a
+// Lister. Other URLs are returned as they are.
+func (f *CompositeFetcher) List(rawURL string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+	}
 
-// NewHTTPFetcher creates a new HTTPFetcher.
-func NewHTTPFetcher() *HTTPFetcher {
-	return &HTTPFetcher{
-		client: &http.Client{},
+	lister, ok := f.fetchers[u.Scheme].(Lister)
+	if !ok {
+		return []string{rawURL}, nil
 	}
+
+	return lister.List(rawURL)
 }
 
-// Fetch fetches the content of a URL and returns it as a byte slice.
-func (f *HTTPFetcher) Fetch(url string) ([]byte, string, error) {
-	resp, err := f.client.Get(url)
+// ContentType returns the media type of the content last fetched from a URL,
+// if the fetcher for its scheme is a ContentTyper.
+func (f *CompositeFetcher) ContentType(rawURL string) string {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to fetch url %s: %w", url, err)
+		return ""
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("failed to fetch url %s: status code %d", url, resp.StatusCode)
+	ct, ok := f.fetchers[u.Scheme].(ContentTyper)
+	if !ok {
+		return ""
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return ct.ContentType(rawURL)
+}
+
+// CommitSignature returns the signature of a commit that a URL was fetched
+// at, if the fetcher for its scheme is a CommitFetcher.
+func (f *CompositeFetcher) CommitSignature(rawURL, hash string) (string, []byte, error) {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, "", err
+		return "", nil, fmt.Errorf("failed to parse url %s: %w", rawURL, err)
 	}
 
-	// Prefer ETag, but fall back to Last-Modified.
-	var state string
-	if etag := resp.Header.Get("ETag"); etag != "" {
-		state = etag
-	} else if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
-		state = lastModified
-	} else {
-		state = fmt.Sprintf("%x", sha256.Sum256(body))
+	cf, ok := f.fetchers[u.Scheme].(CommitFetcher)
+	if !ok {
+		return "", nil, fmt.Errorf("commit signatures are not supported for scheme: %s", u.Scheme)
 	}
 
-	return body, state, nil
+	return cf.CommitSignature(rawURL, hash)
 }
 
 // FileFetcher is an implementation of Fetcher that fetches content from a local file.
@@ -122,59 +142,45 @@ func (f *FileFetcher) Fetch(rawURL string) ([]byte, string, error) {
 	return data, fmt.Sprintf("%x", sha256.Sum256(data)), nil
 }
 
-// Parser defines the interface for parsing content into a list of calls.
-type Parser interface {
-	Parse(url string, data []byte) (*Source, error)
-}
-
-// YAMLParser is an implementation of Parser that parses YAML content.
-type YAMLParser struct{}
-
-// NewYAMLParser creates a new YAMLParser.
-func NewYAMLParser() *YAMLParser {
-	return &YAMLParser{}
-}
-
-// Parse parses a YAML byte slice and returns a list of calls.
-func (p *YAMLParser) Parse(rawURL string, data []byte) (*Source, error) {
-	var s Source
-	if err := yaml.Unmarshal(data, &s); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal yaml: %w", err)
-	}
-
-	p.fillCampaign(rawURL, &s)
-
-	// Add the campaign to each call.
-	for i := range s.Calls {
-		s.Calls[i].Campaign = s.Campaign
+// List expands a URL to a directory, or a glob such as
+// "file:///etc/ruf/campaigns/*.yaml", into the URLs of the source files in it.
+// Directories are not searched recursively. Any other URL is returned as it is.
+func (f *FileFetcher) List(rawURL string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url %s: %w", rawURL, err)
 	}
 
-	return &s, nil
-}
-
-func (p *YAMLParser) fillCampaign(rawURL string, s *Source) error {
-	// If the campaign isn't specified, we'll derive it from the filename.
-	if s.Campaign.ID == "" {
-		u, err := url.Parse(rawURL)
+	var matches []string
+	if hasMeta(u.Path) {
+		matches, err = filepath.Glob(u.Path)
 		if err != nil {
-			return fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+			return nil, fmt.Errorf("invalid glob %s: %w", rawURL, err)
 		}
-
-		// my-campaign.yaml -> my-campaign-yaml
-		base := u.Path[strings.LastIndex(u.Path, "/")+1:]
-		s.Campaign.ID = strings.ReplaceAll(
-			strings.TrimSuffix(base, ".yaml"),
-			".", "-",
-		)
-	}
-	if s.Campaign.Name == "" {
-		u, err := url.Parse(rawURL)
+	} else {
+		info, err := os.Stat(u.Path)
+		if err != nil || !info.IsDir() {
+			// Errors are reported when the file is fetched.
+			return []string{rawURL}, nil
+		}
+		entries, err := os.ReadDir(u.Path)
 		if err != nil {
-			return fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+			return nil, fmt.Errorf("failed to read directory %s: %w", u.Path, err)
+		}
+		for _, entry := range entries {
+			matches = append(matches, filepath.Join(u.Path, entry.Name()))
 		}
-		s.Campaign.Name = u.Path
 	}
-	return nil
+
+	var urls []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err != nil || info.IsDir() || !isSourceFile(match) {
+			continue
+		}
+		urls = append(urls, withPath(u, filepath.ToSlash(match)))
+	}
+	sort.Strings(urls)
+	return urls, nil
 }
 
 // Sourcer is an interface that defines the methods for sourcing calls.
@@ -196,17 +202,59 @@ func NewSourcer(fetcher Fetcher, parser Parser) Sourcer {
 	}
 }
 
-// Source fetches and parses calls from a URL.
+// Source fetches and parses calls from a URL, and from the sources it includes.
 func (s *sourcer) Source(url string) (*Source, string, error) {
+	inc := &includer{sourcer: s, seen: make(map[string]bool), ids: make(map[string]string)}
+	source, err := inc.load(url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for file := range inc.seen {
+		if file != url {
+			source.Included = append(source.Included, file)
+		}
+	}
+	sort.Strings(source.Included)
+	source.URL = url
+
+	// Included calls are part of the campaign of the source that includes them.
+	for i := range source.Calls {
+		source.Calls[i].Campaign = source.Campaign
+	}
+
+	return source, inc.state(), nil
+}
+
+// parse fetches and parses a single source file.
+func (s *sourcer) parse(url string) (*Source, string, error) {
 	data, state, err := s.fetcher.Fetch(url)
 	if err != nil {
 		return nil, "", err
 	}
 
-	source, err := s.parser.Parse(url, data)
+	var source *Source
+	if p, ok := s.parser.(ContentTypeParser); ok {
+		contentType := ""
+		if ct, ok := s.fetcher.(ContentTyper); ok {
+			contentType = ct.ContentType(url)
+		}
+		source, err = p.ParseContentType(url, contentType, data)
+	} else {
+		source, err = s.parser.Parse(url, data)
+	}
 	if err != nil {
 		return nil, "", err
 	}
 
 	return source, state, nil
 }
+
+// List expands a URL into the URLs of the source files it refers to, if the
+// fetcher is a Lister.
+func (s *sourcer) List(url string) ([]string, error) {
+	lister, ok := s.fetcher.(Lister)
+	if !ok {
+		return []string{url}, nil
+	}
+	return lister.List(url)
+}
@@ -5,9 +5,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCompositeFetcher(t *testing.T) {
@@ -77,3 +79,64 @@ calls:
 	assert.Equal(t, "test", source.Calls[0].Campaign.ID)
 	assert.Equal(t, "/test.yaml", source.Calls[0].Campaign.Name)
 }
+
+func TestFileFetcher_List(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yml", "c.txt", ".d.yaml", "sub/e.yaml"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("calls: []"), 0644))
+	}
+
+	fetcher := NewFileFetcher()
+	testCases := []struct {
+		name     string
+		url      string
+		expected []string
+	}{
+		{"directory", "file://" + dir, []string{"file://" + dir + "/a.yaml", "file://" + dir + "/b.yml"}},
+		{"glob", "file://" + dir + "/*.yaml", []string{"file://" + dir + "/a.yaml"}},
+		{"nested glob", "file://" + dir + "/*/*.yaml", []string{"file://" + dir + "/sub/e.yaml"}},
+		{"no matches", "file://" + dir + "/*.toml", nil},
+		{"file", "file://" + dir + "/c.txt", []string{"file://" + dir + "/c.txt"}},
+		{"missing file", "file://" + dir + "/missing.yaml", []string{"file://" + dir + "/missing.yaml"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			urls, err := fetcher.List(tc.url)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, urls)
+		})
+	}
+
+	_, err := fetcher.List("file://" + dir + "/[.yaml")
+	assert.ErrorContains(t, err, "invalid glob")
+}
+
+func TestSourcer_List(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "first.yaml"), []byte("calls:\n  - id: one\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "second.yaml"), []byte("calls:\n  - id: two\n"), 0644))
+
+	fetcher := NewCompositeFetcher()
+	fetcher.AddFetcher("file", NewFileFetcher())
+	s := NewSourcer(fetcher, NewYAMLParser())
+
+	urls, err := List(s, "file://"+dir+"/")
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+
+	// Each file gets its own campaign.
+	var campaigns []string
+	for _, u := range urls {
+		source, _, err := s.Source(u)
+		require.NoError(t, err)
+		campaigns = append(campaigns, source.Calls[0].Campaign.ID)
+	}
+	assert.Equal(t, []string{"first", "second"}, campaigns)
+
+	// Schemes without a Lister are returned as they are.
+	urls, err = List(s, "https://example.com/calls/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/calls/"}, urls)
+}
@@ -0,0 +1,384 @@
+package sourcer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrUnverified is returned for sources whose signature is missing or can't
+// be verified.
+var ErrUnverified = errors.New("source signature could not be verified")
+
+// The namespaces of SSH signatures of files, as made by
+// "ssh-keygen -Y sign -n file", and of git commits.
+const (
+	sshFileNamespace = "file"
+	sshGitNamespace  = "git"
+)
+
+// VerifyRule requires the sources whose URL starts with URL to be signed by
+// one of Keys. Keys are minisign public keys, SSH public keys in the
+// authorized_keys format, or armored PGP public keys.
+type VerifyRule struct {
+	URL  string   `mapstructure:"url"`
+	Keys []string `mapstructure:"keys"`
+}
+
+// CommitFetcher is implemented by fetchers of version controlled sources,
+// which are verified by the signature of the commit they were fetched at,
+// rather than by a detached signature.
+type CommitFetcher interface {
+	// CommitSignature returns the signature of the commit with a hash, as
+	// returned by Fetch, and the content that it signs.
+	CommitSignature(url, hash string) (string, []byte, error)
+}
+
+// VerifyingFetcher is a Fetcher that verifies the signature of sources before
+// they are used. Sources from git repositories are verified by the signature
+// of the commit they were fetched at, and other sources by a detached
+// signature at "<url>.sig". Sources that no rule matches are not verified.
+type VerifyingFetcher struct {
+	fetcher Fetcher
+	rules   []verifyRule
+}
+
+// verifyRule is a VerifyRule with its keys parsed.
+type verifyRule struct {
+	prefix string
+	keys   *keyring
+}
+
+// NewVerifyingFetcher creates a new VerifyingFetcher that verifies the
+// sources fetched by another fetcher.
+func NewVerifyingFetcher(fetcher Fetcher, rules []VerifyRule) (*VerifyingFetcher, error) {
+	f := &VerifyingFetcher{fetcher: fetcher}
+	for _, rule := range rules {
+		if rule.URL == "" {
+			return nil, fmt.Errorf("invalid verify rule: url is required")
+		}
+		keys, err := parseKeys(rule.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keys for %s: %w", rule.URL, err)
+		}
+		f.rules = append(f.rules, verifyRule{prefix: rule.URL, keys: keys})
+	}
+	return f, nil
+}
+
+// Fetch fetches the content of a URL, and verifies its signature if a rule
+// matches it.
+func (f *VerifyingFetcher) Fetch(rawURL string) ([]byte, string, error) {
+	data, state, err := f.fetcher.Fetch(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys := f.keys(rawURL)
+	if keys == nil {
+		return data, state, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+	}
+
+	if u.Scheme == "git" || strings.HasPrefix(u.Scheme, "git+") {
+		err = f.verifyCommit(rawURL, state, keys)
+	} else {
+		err = f.verifyDetached(u, data, keys)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s: %v", ErrUnverified, rawURL, err)
+	}
+
+	return data, state, nil
+}
+
+// List expands a URL with the underlying fetcher, if it is a Lister.
+func (f *VerifyingFetcher) List(rawURL string) ([]string, error) {
+	lister, ok := f.fetcher.(Lister)
+	if !ok {
+		return []string{rawURL}, nil
+	}
+	return lister.List(rawURL)
+}
+
+// ContentType returns the media type of the content last fetched from a URL,
+// if the underlying fetcher is a ContentTyper.
+func (f *VerifyingFetcher) ContentType(rawURL string) string {
+	ct, ok := f.fetcher.(ContentTyper)
+	if !ok {
+		return ""
+	}
+	return ct.ContentType(rawURL)
+}
+
+// keys returns the keys of the rule with the longest URL prefix that matches a
+// URL, or nil if no rule matches it.
+func (f *VerifyingFetcher) keys(rawURL string) *keyring {
+	var match *verifyRule
+	for i, rule := range f.rules {
+		if strings.HasPrefix(rawURL, rule.prefix) && (match == nil || len(rule.prefix) > len(match.prefix)) {
+			match = &f.rules[i]
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	return match.keys
+}
+
+// verifyDetached verifies the detached signature of a source at "<url>.sig".
+func (f *VerifyingFetcher) verifyDetached(u *url.URL, data []byte, keys *keyring) error {
+	sigURL := withPath(u, u.Path+".sig")
+	sig, _, err := f.fetcher.Fetch(sigURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signature %s: %w", sigURL, err)
+	}
+	return keys.verify(sshFileNamespace, data, string(sig))
+}
+
+// verifyCommit verifies the signature of the commit a source was fetched at.
+func (f *VerifyingFetcher) verifyCommit(rawURL, hash string, keys *keyring) error {
+	cf, ok := f.fetcher.(CommitFetcher)
+	if !ok {
+		return fmt.Errorf("commit signatures are not supported")
+	}
+	sig, payload, err := cf.CommitSignature(rawURL, hash)
+	if err != nil {
+		return err
+	}
+	if sig == "" {
+		return fmt.Errorf("commit %s is not signed", hash)
+	}
+	if err := keys.verify(sshGitNamespace, payload, sig); err != nil {
+		return fmt.Errorf("commit %s: %w", hash, err)
+	}
+	return nil
+}
+
+// keyring is a set of public keys that signatures are verified against.
+type keyring struct {
+	minisign []minisignKey
+	ssh      []ssh.PublicKey
+	pgp      openpgp.EntityList
+}
+
+// minisignKey is a minisign public key.
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// parseKeys parses minisign, SSH and armored PGP public keys.
+func parseKeys(keys []string) (*keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys configured")
+	}
+
+	k := &keyring{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		switch {
+		case strings.HasPrefix(key, "-----BEGIN PGP PUBLIC KEY BLOCK-----"):
+			entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse pgp key: %w", err)
+			}
+			k.pgp = append(k.pgp, entities...)
+		case strings.HasPrefix(key, "ssh-") || strings.HasPrefix(key, "ecdsa-"):
+			pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ssh key: %w", err)
+			}
+			k.ssh = append(k.ssh, pub)
+		default:
+			mk, err := parseMinisignKey(key)
+			if err != nil {
+				return nil, err
+			}
+			k.minisign = append(k.minisign, mk)
+		}
+	}
+	return k, nil
+}
+
+// parseMinisignKey parses a minisign public key, either on its own or as the
+// contents of a minisign.pub file.
+func parseMinisignKey(key string) (minisignKey, error) {
+	lines := strings.Split(key, "\n")
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(buf) != 2+8+ed25519.PublicKeySize || string(buf[:2]) != "Ed" {
+		return minisignKey{}, fmt.Errorf("unrecognized key %q: expected a minisign, ssh or pgp public key", key)
+	}
+	return minisignKey{id: buf[2:10], key: buf[10:]}, nil
+}
+
+// verify verifies a signature of data. SSH signatures must be made for the
+// namespace.
+func (k *keyring) verify(namespace string, data []byte, sig string) error {
+	sig = strings.TrimSpace(sig)
+	switch {
+	case strings.HasPrefix(sig, "-----BEGIN SSH SIGNATURE-----"):
+		return k.verifySSH(namespace, data, sig)
+	case strings.HasPrefix(sig, "-----BEGIN PGP SIGNATURE-----"):
+		if len(k.pgp) == 0 {
+			return fmt.Errorf("pgp signature, but no pgp keys configured")
+		}
+		if _, err := openpgp.CheckArmoredDetachedSignature(k.pgp, bytes.NewReader(data), strings.NewReader(sig), nil); err != nil {
+			return fmt.Errorf("bad pgp signature: %w", err)
+		}
+		return nil
+	default:
+		return k.verifyMinisign(data, sig)
+	}
+}
+
+// sshSignature is the blob of an SSH signature, as described in
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// verifySSH verifies an armored SSH signature.
+func (k *keyring) verifySSH(namespace string, data []byte, armored string) error {
+	body := strings.TrimPrefix(armored, "-----BEGIN SSH SIGNATURE-----")
+	body, _, _ = strings.Cut(body, "-----END SSH SIGNATURE-----")
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return fmt.Errorf("failed to decode ssh signature: %w", err)
+	}
+
+	blob, ok := bytes.CutPrefix(blob, []byte("SSHSIG"))
+	if !ok {
+		return fmt.Errorf("invalid ssh signature")
+	}
+	var s sshSignature
+	if err := ssh.Unmarshal(blob, &s); err != nil {
+		return fmt.Errorf("invalid ssh signature: %w", err)
+	}
+	if s.Version != 1 {
+		return fmt.Errorf("unsupported ssh signature version %d", s.Version)
+	}
+	if s.Namespace != namespace {
+		return fmt.Errorf("ssh signature is for namespace %q, not %q", s.Namespace, namespace)
+	}
+
+	pub, err := ssh.ParsePublicKey(s.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid ssh signature key: %w", err)
+	}
+	trusted := false
+	for _, key := range k.ssh {
+		if bytes.Equal(key.Marshal(), pub.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return fmt.Errorf("ssh signature is by an unknown key %s", ssh.FingerprintSHA256(pub))
+	}
+
+	var hash []byte
+	switch s.HashAlgorithm {
+	case "sha256":
+		sum := sha256.Sum256(data)
+		hash = sum[:]
+	case "sha512":
+		sum := sha512.Sum512(data)
+		hash = sum[:]
+	default:
+		return fmt.Errorf("unsupported ssh signature hash %q", s.HashAlgorithm)
+	}
+
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(s.Signature, &sig); err != nil {
+		return fmt.Errorf("invalid ssh signature: %w", err)
+	}
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{s.Namespace, s.Reserved, s.HashAlgorithm, hash})...)
+	if err := pub.Verify(signed, &sig); err != nil {
+		return fmt.Errorf("bad ssh signature: %w", err)
+	}
+	return nil
+}
+
+// verifyMinisign verifies a minisign signature, as described in
+// https://jedisct1.github.io/minisign/#signature-format.
+func (k *keyring) verifyMinisign(data []byte, sig string) error {
+	lines := strings.Split(strings.ReplaceAll(sig, "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("unrecognized signature: expected a minisign, ssh or pgp signature")
+	}
+
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(buf) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	alg, id, signature := string(buf[:2]), buf[2:10], buf[10:]
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+
+	var key *minisignKey
+	for i := range k.minisign {
+		if bytes.Equal(k.minisign[i].id, id) {
+			key = &k.minisign[i]
+			break
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("minisign signature is by an unknown key %X", reverse(id))
+	}
+
+	switch alg {
+	case "Ed":
+	case "ED":
+		sum := blake2b.Sum512(data)
+		data = sum[:]
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %q", alg)
+	}
+	if !ed25519.Verify(key.key, data, signature) {
+		return fmt.Errorf("bad minisign signature")
+	}
+
+	// The global signature covers the trusted comment.
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(key.key, append(append([]byte{}, signature...), comment...), global) {
+		return fmt.Errorf("bad minisign trusted comment signature")
+	}
+	return nil
+}
+
+// reverse returns a reversed copy of a byte slice. Minisign key IDs are
+// little-endian, but shown big-endian.
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
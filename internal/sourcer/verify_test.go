@@ -0,0 +1,252 @@
+package sourcer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ssh"
+)
+
+// testdataSSHKey is the key that signed testdata/signed/calls.yaml, with
+// "ssh-keygen -Y sign -n file".
+const testdataSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGaWLhC4ZFlbCIM1HIg+0Q+aBpl1L1w6q4p2aw+jnTg3 ruf"
+
+// sshSigner signs data in the SSH signature format, as "ssh-keygen -Y sign"
+// does.
+type sshSigner struct {
+	signer    ssh.Signer
+	namespace string
+}
+
+func newSSHSigner(t *testing.T, namespace string) (*sshSigner, string) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	return &sshSigner{signer: signer, namespace: namespace}, string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
+
+func (s *sshSigner) Sign(message io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha512.Sum512(data)
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{s.namespace, "", "sha512", hash[:]})...)
+	sig, err := s.signer.Sign(rand.Reader, signed)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(sshSignature{
+		Version:       1,
+		PublicKey:     s.signer.PublicKey().Marshal(),
+		Namespace:     s.namespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var b strings.Builder
+	b.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		b.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	b.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+	return []byte(b.String()), nil
+}
+
+// minisign returns a new minisign public key, and a function that signs data
+// with it, as "minisign -S" does.
+func minisign(t *testing.T) (string, func(data []byte, comment string) string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	id := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	key := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...))
+
+	return key, func(data []byte, comment string) string {
+		hash := blake2b.Sum512(data)
+		sig := ed25519.Sign(priv, hash[:])
+		global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+		return fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+			base64.StdEncoding.EncodeToString(append(append([]byte("ED"), id...), sig...)),
+			comment,
+			base64.StdEncoding.EncodeToString(global))
+	}
+}
+
+func TestVerifyingFetcher_SSH(t *testing.T) {
+	dir, err := filepath.Abs("testdata/signed")
+	require.NoError(t, err)
+	tampered := t.TempDir()
+	writeFiles(t, tampered, map[string]string{
+		"calls.yaml":     "calls: [{id: evil}]\n",
+		"calls.yaml.sig": readFile(t, filepath.Join(dir, "calls.yaml.sig")),
+		"unsigned.yaml":  "calls: []\n",
+	})
+	_, otherKey := newSSHSigner(t, sshFileNamespace)
+
+	f, err := NewVerifyingFetcher(NewFileFetcher(), []VerifyRule{
+		{URL: "file://" + dir, Keys: []string{testdataSSHKey}},
+		{URL: "file://" + tampered, Keys: []string{testdataSSHKey}},
+		{URL: "file://" + filepath.Join(dir, "calls.yaml"), Keys: []string{otherKey}},
+	})
+	require.NoError(t, err)
+
+	_, _, err = f.Fetch("file://" + tampered + "/calls.yaml")
+	assert.ErrorIs(t, err, ErrUnverified)
+	assert.ErrorContains(t, err, "bad ssh signature")
+
+	_, _, err = f.Fetch("file://" + tampered + "/unsigned.yaml")
+	assert.ErrorIs(t, err, ErrUnverified)
+	assert.ErrorContains(t, err, "failed to fetch signature")
+
+	// The rule with the longest prefix is used.
+	_, _, err = f.Fetch("file://" + dir + "/calls.yaml")
+	assert.ErrorContains(t, err, "ssh signature is by an unknown key SHA256:")
+
+	f, err = NewVerifyingFetcher(NewFileFetcher(), []VerifyRule{{URL: "file://" + dir, Keys: []string{testdataSSHKey}}})
+	require.NoError(t, err)
+	data, _, err := f.Fetch("file://" + dir + "/calls.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "calls: []\n", string(data))
+
+	// Sources that no rule matches are not verified.
+	data, _, err = f.Fetch("file://" + tampered + "/unsigned.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "calls: []\n", string(data))
+}
+
+func TestVerifyingFetcher_Minisign(t *testing.T) {
+	key, sign := minisign(t)
+	dir := t.TempDir()
+	data := []byte("calls: []\n")
+	sig := sign(data, "timestamp:1735689600")
+	writeFiles(t, dir, map[string]string{
+		"calls.yaml":           string(data),
+		"calls.yaml.sig":       sig,
+		"comment.yaml":         string(data),
+		"comment.yaml.sig":     strings.Replace(sig, "timestamp:1735689600", "timestamp:1735689601", 1),
+		"unknown.yaml":         string(data),
+		"unknown.yaml.sig":     strings.Replace(sig, "RUQBAgMEBQYHC", "RUQCAgMEBQYHC", 1),
+		"unsupported.yaml":     string(data),
+		"unsupported.yaml.sig": "not a signature\n",
+	})
+
+	f, err := NewVerifyingFetcher(NewFileFetcher(), []VerifyRule{{URL: "file://", Keys: []string{key}}})
+	require.NoError(t, err)
+
+	got, _, err := f.Fetch("file://" + dir + "/calls.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, _, err = f.Fetch("file://" + dir + "/comment.yaml")
+	assert.ErrorContains(t, err, "bad minisign trusted comment signature")
+	_, _, err = f.Fetch("file://" + dir + "/unknown.yaml")
+	assert.ErrorContains(t, err, "minisign signature is by an unknown key")
+	_, _, err = f.Fetch("file://" + dir + "/unsupported.yaml")
+	assert.ErrorContains(t, err, "unrecognized signature")
+}
+
+func TestVerifyingFetcher_Git(t *testing.T) {
+	origin := t.TempDir()
+	r, err := git.PlainInit(origin, false)
+	require.NoError(t, err)
+	unsigned := commitFile(t, r, "calls.yaml", "calls: []\n")
+
+	url := "git+file://" + origin + "//calls.yaml"
+	sshSigner, sshKey := newSSHSigner(t, sshGitNamespace)
+	entity, err := openpgp.NewEntity("ruf", "", "ruf@example.com", nil)
+	require.NoError(t, err)
+	var pgpKey strings.Builder
+	w, err := armor.Encode(&pgpKey, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	gf := newLocalGitFetcher(t)
+	fetcher := NewCompositeFetcher()
+	fetcher.AddFetcher("git+file", gf)
+	f, err := NewVerifyingFetcher(fetcher, []VerifyRule{{URL: "git+file://", Keys: []string{sshKey, pgpKey.String()}}})
+	require.NoError(t, err)
+
+	_, _, err = f.Fetch(url)
+	assert.ErrorIs(t, err, ErrUnverified)
+	assert.ErrorContains(t, err, fmt.Sprintf("commit %s is not signed", unsigned))
+
+	commit := func(content string, opts *git.CommitOptions) {
+		t.Helper()
+		wt, err := r.Worktree()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(origin, "calls.yaml"), []byte(content), 0644))
+		_, err = wt.Add("calls.yaml")
+		require.NoError(t, err)
+		opts.Author = &object.Signature{Name: "ruf", Email: "ruf@example.com", When: time.Now()}
+		_, err = wt.Commit("Update calls.yaml", opts)
+		require.NoError(t, err)
+	}
+
+	commit("calls: [{id: ssh}]\n", &git.CommitOptions{Signer: sshSigner})
+	data, _, err := f.Fetch(url)
+	require.NoError(t, err)
+	assert.Equal(t, "calls: [{id: ssh}]\n", string(data))
+
+	commit("calls: [{id: pgp}]\n", &git.CommitOptions{SignKey: entity})
+	data, _, err = f.Fetch(url)
+	require.NoError(t, err)
+	assert.Equal(t, "calls: [{id: pgp}]\n", string(data))
+
+	// Signatures by other keys are rejected.
+	other, _ := newSSHSigner(t, sshGitNamespace)
+	commit("calls: [{id: other}]\n", &git.CommitOptions{Signer: other})
+	_, _, err = f.Fetch(url)
+	assert.ErrorContains(t, err, "ssh signature is by an unknown key")
+}
+
+func TestParseKeys(t *testing.T) {
+	_, err := NewVerifyingFetcher(NewFileFetcher(), []VerifyRule{{URL: "file://"}})
+	assert.ErrorContains(t, err, "no keys configured")
+
+	_, err = NewVerifyingFetcher(NewFileFetcher(), []VerifyRule{{Keys: []string{testdataSSHKey}}})
+	assert.ErrorContains(t, err, "url is required")
+
+	_, err = NewVerifyingFetcher(NewFileFetcher(), []VerifyRule{{URL: "file://", Keys: []string{"hunter2"}}})
+	assert.ErrorContains(t, err, "expected a minisign, ssh or pgp public key")
+
+	_, err = NewVerifyingFetcher(NewFileFetcher(), []VerifyRule{{URL: "file://", Keys: []string{"ssh-ed25519 AAAA"}}})
+	assert.ErrorContains(t, err, "failed to parse ssh key")
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/andrewhowdencom/ruf/internal/clients/github"
+	"github.com/andrewhowdencom/ruf/internal/clients/sms"
 	"github.com/andrewhowdencom/ruf/internal/model"
 	"github.com/gorhill/cronexpr"
 )
@@ -20,6 +22,45 @@ func Validate(calls []*model.Call) []error {
 	return errs
 }
 
+// ValidateEvents validates a list of events and returns a list of errors.
+func ValidateEvents(events []model.Event) []error {
+	var errs []error
+	for _, event := range events {
+		if err := validateEvent(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func validateEvent(event model.Event) error {
+	var errs []string
+	if event.Sequence == "" {
+		errs = append(errs, "sequence is required")
+	}
+	if event.StartTime.IsZero() {
+		errs = append(errs, "start_time is required")
+	}
+	if !event.EndTime.IsZero() && event.Duration != "" {
+		errs = append(errs, "only one of end_time and duration can be set")
+	}
+	if !event.EndTime.IsZero() && event.EndTime.Before(event.StartTime) {
+		errs = append(errs, "end_time must not be before start_time")
+	}
+	if event.Duration != "" {
+		if d, err := time.ParseDuration(event.Duration); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid duration: %s", err))
+		} else if d < 0 {
+			errs = append(errs, "duration must not be negative")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("validation failed for event '%s': %s", event.Sequence, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
 func validateCall(call *model.Call) error {
 	var errs []string
 	if call.Subject == "" {
@@ -35,6 +76,15 @@ func validateCall(call *model.Call) error {
 		errs = append(errs, "at least one trigger is required")
 	}
 
+	for _, field := range call.IgnoreDefaults {
+		switch field {
+		case "author", "subject", "destinations", "triggers":
+			// Valid
+		default:
+			errs = append(errs, fmt.Sprintf("invalid ignore_defaults field: %s (must be one of author, subject, destinations or triggers)", field))
+		}
+	}
+
 	for _, trigger := range call.Triggers {
 		if err := validateTrigger(trigger); err != nil {
 			errs = append(errs, err.Error())
@@ -76,8 +126,28 @@ func validateTrigger(trigger model.Trigger) error {
 
 func validateDestination(destination model.Destination) error {
 	switch destination.Type {
-	case "slack", "email":
+	case "slack", "email", "teams", "discord", "mattermost", "matrix", "telegram", "googlechat", "file", "feed":
 		// Valid
+	case "ntfy":
+		if destination.Priority < 0 || destination.Priority > 5 {
+			return fmt.Errorf("invalid ntfy priority: %d (must be between 0 and 5, where 0 uses the server default)", destination.Priority)
+		}
+	case "github":
+		for _, to := range destination.To {
+			if _, err := github.ParseAddress(to); err != nil {
+				return err
+			}
+		}
+	case "sms":
+		for _, to := range destination.To {
+			if !sms.E164.MatchString(to) {
+				return fmt.Errorf("invalid sms number: %s (must be in E.164 format, such as +14155550100)", to)
+			}
+		}
+	case "gotify":
+		if destination.Priority < 0 || destination.Priority > 10 {
+			return fmt.Errorf("invalid gotify priority: %d (must be between 0 and 10)", destination.Priority)
+		}
 	default:
 		return fmt.Errorf("invalid destination type: %s", destination.Type)
 	}
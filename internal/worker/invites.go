@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/andrewhowdencom/ruf/internal/calendar"
+	"github.com/andrewhowdencom/ruf/internal/clients/email"
+	"github.com/andrewhowdencom/ruf/internal/datastore"
+	"github.com/andrewhowdencom/ruf/internal/model"
+	"github.com/andrewhowdencom/ruf/internal/sourcer"
+	"github.com/spf13/viper"
+)
+
+// inviteUID returns the calendar UID of an event. It does not depend on the
+// start time, so that invites for an event that moves are updated in place.
+func inviteUID(campaignID string, event *model.Event) string {
+	key := event.ID
+	if key == "" {
+		key = event.Sequence
+	}
+	return calendar.UID(campaignID, key)
+}
+
+// eventEnd returns the end of an event from either its end time or its
+// duration. It returns the zero time if neither is set.
+func eventEnd(event *model.Event) (time.Time, error) {
+	if !event.EndTime.IsZero() {
+		return event.EndTime, nil
+	}
+	if event.Duration == "" {
+		return time.Time{}, nil
+	}
+	duration, err := time.ParseDuration(event.Duration)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse event duration: %w", err)
+	}
+	return event.StartTime.Add(duration), nil
+}
+
+// attachInvite attaches a calendar invite for the call's event to the message,
+// and returns the record to store once the message has been sent. It does
+// nothing if the call was not triggered by an event that wants invites.
+func (w *Worker) attachInvite(call *model.Call, to, subject, content string, msg *email.Message) (*datastore.Invite, error) {
+	if call.Event == nil || !call.Event.Invite {
+		return nil, nil
+	}
+
+	end, err := eventEnd(call.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := call.Event.Title
+	if summary == "" {
+		summary = subject
+	}
+
+	record := &datastore.Invite{
+		UID:        inviteUID(call.Campaign.ID, call.Event),
+		CampaignID: call.Campaign.ID,
+		SourceURL:  call.SourceURL,
+		Recipient:  to,
+		Summary:    summary,
+		StartTime:  call.Event.StartTime,
+		EndTime:    end,
+		Status:     datastore.StatusSent,
+	}
+
+	// Recipients that already have the invite get the same sequence number,
+	// unless it has changed since they got it.
+	previous, err := w.store.GetInvite(record.UID, to)
+	switch {
+	case err == nil:
+		record.Sequence = previous.Sequence
+		if previous.Status != datastore.StatusSent || !previous.StartTime.Equal(record.StartTime) || !previous.EndTime.Equal(record.EndTime) {
+			record.Sequence++
+		}
+	case !errors.Is(err, datastore.ErrNotFound):
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+
+	invite := &calendar.Invite{
+		UID:         record.UID,
+		Method:      calendar.MethodRequest,
+		Sequence:    record.Sequence,
+		Summary:     summary,
+		Description: content,
+		Organizer:   viper.GetString("email.from"),
+		Attendees:   []string{to},
+		Start:       record.StartTime,
+		End:         record.EndTime,
+	}
+	msg.Calendar = invite.Render()
+	msg.CalendarMethod = invite.Method
+
+	return record, nil
+}
+
+// reconcileInvites sends an update to everyone invited to an event that has
+// moved, and a cancellation to everyone invited to an event that has been
+// removed from its source. Only sources that have changed are considered, and
+// events that have already ended are left alone.
+func (w *Worker) reconcileInvites(sources []*sourcer.Source) error {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	invites, err := w.store.ListInvites()
+	if err != nil {
+		return fmt.Errorf("failed to list invites: %w", err)
+	}
+
+	now := time.Now()
+	for _, source := range sources {
+		events := make(map[string]*model.Event)
+		for i := range source.Events {
+			if source.Events[i].Invite {
+				events[inviteUID(source.Campaign.ID, &source.Events[i])] = &source.Events[i]
+			}
+		}
+
+		for _, inv := range invites {
+			if inv.Status != datastore.StatusSent || ended(inv.StartTime, inv.EndTime, now) {
+				continue
+			}
+			// Several sources can share a campaign, so invites are matched to
+			// the source that defines their event.
+			if inv.SourceURL != source.URL && (inv.SourceURL != "" || inv.CampaignID != source.Campaign.ID) {
+				continue
+			}
+
+			event, ok := events[inv.UID]
+			if !ok {
+				// Invites recorded without a source could be for an event in
+				// another source of the campaign, so they are never cancelled.
+				if inv.SourceURL == "" {
+					continue
+				}
+				if err := w.sendInviteChange(inv, calendar.MethodCancel, inv.StartTime, inv.EndTime); err != nil {
+					slog.Error("failed to send invite cancellation", "uid", inv.UID, "recipient", inv.Recipient, "error", err)
+				}
+				continue
+			}
+
+			end, err := eventEnd(event)
+			if err != nil {
+				slog.Error("failed to determine event end", "uid", inv.UID, "error", err)
+				continue
+			}
+			if event.StartTime.Equal(inv.StartTime) && end.Equal(inv.EndTime) {
+				continue
+			}
+			inv.SourceURL = source.URL
+			if err := w.sendInviteChange(inv, calendar.MethodRequest, event.StartTime, end); err != nil {
+				slog.Error("failed to send invite update", "uid", inv.UID, "recipient", inv.Recipient, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sendInviteChange sends a new revision of an invite to its recipient, and
+// records it once sent.
+func (w *Worker) sendInviteChange(inv *datastore.Invite, method string, start, end time.Time) error {
+	invite := &calendar.Invite{
+		UID:       inv.UID,
+		Method:    method,
+		Sequence:  inv.Sequence + 1,
+		Summary:   inv.Summary,
+		Organizer: viper.GetString("email.from"),
+		Attendees: []string{inv.Recipient},
+		Start:     start,
+		End:       end,
+	}
+
+	msg := &email.Message{
+		To:             []string{inv.Recipient},
+		Subject:        "Updated: " + inv.Summary,
+		Body:           changeSummary(inv, start, end),
+		Calendar:       invite.Render(),
+		CalendarMethod: method,
+	}
+	if method == calendar.MethodCancel {
+		msg.Subject = "Cancelled: " + inv.Summary
+		msg.Body = fmt.Sprintf("%s has been cancelled.", inv.Summary)
+	}
+
+	slog.Info("sending invite change", "uid", inv.UID, "recipient", inv.Recipient, "method", method)
+	if err := w.emailClient.Send(msg); err != nil {
+		return err
+	}
+
+	inv.Sequence = invite.Sequence
+	inv.StartTime = start
+	inv.EndTime = end
+	if method == calendar.MethodCancel {
+		inv.Status = datastore.StatusDeleted
+	}
+	return w.store.AddInvite(inv)
+}
+
+// changeSummary describes how the time of an invite's event has changed.
+func changeSummary(inv *datastore.Invite, start, end time.Time) string {
+	switch {
+	case end.IsZero():
+		return fmt.Sprintf("%s has moved to %s.", inv.Summary, start.Format(time.RFC1123))
+	case start.Equal(inv.StartTime):
+		return fmt.Sprintf("%s now ends at %s.", inv.Summary, end.Format(time.RFC1123))
+	default:
+		return fmt.Sprintf("%s now runs from %s to %s.", inv.Summary, start.Format(time.RFC1123), end.Format(time.RFC1123))
+	}
+}
+
+// ended reports whether an event has ended, or has started if it has no end.
+func ended(start, end, now time.Time) bool {
+	if end.IsZero() {
+		end = start
+	}
+	return end.Before(now)
+}
@@ -3,8 +3,10 @@ package worker
 import (
 	"fmt"
 	"log/slog"
+	"sort"
 	"time"
 
+	"github.com/andrewhowdencom/ruf/internal/clients"
 	"github.com/andrewhowdencom/ruf/internal/clients/email"
 	"github.com/andrewhowdencom/ruf/internal/clients/slack"
 	"github.com/andrewhowdencom/ruf/internal/datastore"
@@ -21,6 +23,7 @@ type Worker struct {
 	store       datastore.Storer
 	slackClient slack.Client
 	emailClient email.Client
+	senders     map[string]clients.Sender
 	poller      *poller.Poller
 	interval    time.Duration
 }
@@ -31,11 +34,17 @@ func New(store datastore.Storer, slackClient slack.Client, emailClient email.Cli
 		store:       store,
 		slackClient: slackClient,
 		emailClient: emailClient,
+		senders:     make(map[string]clients.Sender),
 		poller:      poller,
 		interval:    interval,
 	}
 }
 
+// AddSender adds a sender for a given destination type.
+func (w *Worker) AddSender(destType string, sender clients.Sender) {
+	w.senders[destType] = sender
+}
+
 // Run starts the worker.
 func (w *Worker) Run() error {
 	slog.Info("starting worker")
@@ -65,8 +74,25 @@ func (w *Worker) RunTick() error {
 		return err
 	}
 
+	// The email session is reused for every recipient in this tick.
+	defer func() {
+		if err := w.emailClient.Close(); err != nil {
+			slog.Warn("failed to close email session", "error", err)
+		}
+	}()
+
+	if err := w.reconcileInvites(sources); err != nil {
+		slog.Error("error reconciling invites", "error", err)
+	}
+
 	calls := w.expandCalls(sources)
 
+	// Send calls in the order they were scheduled, so that the first call in
+	// a thread is sent before any replies to it.
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].ScheduledAt.Before(calls[j].ScheduledAt)
+	})
+
 	for _, call := range calls {
 		if err := w.processCall(call); err != nil {
 			slog.Error("error processing call", "call_id", call.ID, "error", err)
@@ -82,6 +108,9 @@ func (w *Worker) expandCalls(sources []*sourcer.Source) []*model.Call {
 	var expandedCalls []*model.Call
 
 	for _, source := range sources {
+		// Calls expanded from the same sequence and event, used to thread them together.
+		threads := make(map[string][]*model.Call)
+
 		// Build an event map for the current source to allow for efficient lookups.
 		eventsBySequence := make(map[string][]model.Event)
 		for _, event := range source.Events {
@@ -89,6 +118,7 @@ func (w *Worker) expandCalls(sources []*sourcer.Source) []*model.Call {
 		}
 
 		for _, callDef := range source.Calls {
+			callDef.SourceURL = source.URL
 			for _, trigger := range callDef.Triggers {
 				// Handle direct schedule triggers
 				if !trigger.ScheduledAt.IsZero() {
@@ -127,13 +157,30 @@ func (w *Worker) expandCalls(sources []*sourcer.Source) []*model.Call {
 							newCall := w.createCallFromDefinition(callDef)
 							newCall.ScheduledAt = event.StartTime.Add(delta)
 							newCall.Destinations = append(newCall.Destinations, event.Destinations...)
+							newCall.Event = &event
 							newCall.ID = fmt.Sprintf("%s:sequence:%s:%s", callDef.ID, trigger.Sequence, event.StartTime.Format(time.RFC3339))
 							expandedCalls = append(expandedCalls, newCall)
+
+							thread := trigger.Sequence + "@" + event.StartTime.Format(time.RFC3339)
+							threads[thread] = append(threads[thread], newCall)
 						}
 					}
 				}
 			}
 		}
+
+		// The earliest call of each sequence and event starts the thread.
+		for _, calls := range threads {
+			first := calls[0]
+			for _, call := range calls[1:] {
+				if call.ScheduledAt.Before(first.ScheduledAt) {
+					first = call
+				}
+			}
+			for _, call := range calls {
+				call.ThreadID = first.ID
+			}
+		}
 	}
 	return expandedCalls
 }
@@ -163,7 +210,7 @@ func (w *Worker) processCall(call *model.Call) error {
 	if effectiveScheduledAt.Before(now.Add(-lookbackPeriod)) {
 		slog.Warn("skipping call outside lookback period", "call_id", call.ID, "scheduled_at", effectiveScheduledAt)
 		for _, dest := range call.Destinations {
-			for _, to := range dest.To {
+			for _, to := range addresses(call, dest) {
 				err := w.store.AddSentMessage(call.Campaign.ID, call.ID, &datastore.SentMessage{
 					SourceID:     call.ID,
 					ScheduledAt:  effectiveScheduledAt,
@@ -181,13 +228,14 @@ func (w *Worker) processCall(call *model.Call) error {
 	}
 
 	for _, dest := range call.Destinations {
-		if len(dest.To) == 0 {
+		addrs := addresses(call, dest)
+		if len(addrs) == 0 {
 			slog.Warn("skipping call with no address in `to`", "call_id", call.ID)
 			continue
 		}
 
-		for _, to := range dest.To {
-			hasBeenSent, err := w.store.HasBeenSent(call.Campaign.ID, call.ID, dest.Type, to)
+		for _, to := range addrs {
+			hasBeenSent, err := w.hasBeenSent(call, dest.Type, to)
 			if err != nil {
 				return fmt.Errorf("failed to check if call has been sent: %w", err)
 			}
@@ -257,10 +305,38 @@ func (w *Worker) processCall(call *model.Call) error {
 				}
 			case "email":
 				slog.Info("sending email", "call_id", call.ID, "recipient", to, "scheduled_at", effectiveScheduledAt)
-				err := w.emailClient.Send([]string{to}, call.Author, subject, content)
+				from := viper.GetString("email.from")
+				msg := &email.Message{
+					To:        []string{to},
+					Author:    call.Author,
+					Subject:   subject,
+					Body:      content,
+					MessageID: email.MessageID(from, call.Campaign.ID, call.ID),
+				}
+				if call.ThreadID != "" && call.ThreadID != call.ID {
+					msg.InReplyTo = email.MessageID(from, call.Campaign.ID, call.ThreadID)
+					root, err := w.store.FindSentMessage(call.Campaign.ID, call.ThreadID, dest.Type, to)
+					if err != nil && call.Campaign.LegacyID != "" {
+						root, err = w.store.FindSentMessage(call.Campaign.LegacyID, call.ThreadID, dest.Type, to)
+					}
+					// Reply to the ID that the root was sent with, which differs
+					// from the computed one if the campaign ID has changed since.
+					if err == nil && root.MessageID != "" {
+						msg.InReplyTo = root.MessageID
+					}
+				}
+
+				invite, err := w.attachInvite(call, to, subject, content, msg)
+				if err != nil {
+					slog.Error("failed to attach calendar invite", "call_id", call.ID, "error", err)
+				}
+
+				err = w.emailClient.Send(msg)
 				sentMessage := &datastore.SentMessage{
 					SourceID:     call.ID,
 					ScheduledAt:  effectiveScheduledAt,
+					MessageID:    msg.MessageID,
+					InReplyTo:    msg.InReplyTo,
 					Destination:  to,
 					Type:         dest.Type,
 					CampaignName: call.Campaign.Name,
@@ -272,16 +348,99 @@ func (w *Worker) processCall(call *model.Call) error {
 				} else {
 					sentMessage.Status = datastore.StatusSent
 					slog.Info("sent email", "call_id", call.ID, "recipient", to, "scheduled_at", effectiveScheduledAt)
+
+					if invite != nil {
+						if err := w.store.AddInvite(invite); err != nil {
+							slog.Error("failed to record calendar invite", "call_id", call.ID, "error", err)
+						}
+					}
 				}
 
 				if err := w.store.AddSentMessage(call.Campaign.ID, call.ID, sentMessage); err != nil {
 					return err
 				}
 			default:
-				return fmt.Errorf("unsupported destination type: %s", dest.Type)
+				sender, ok := w.senders[dest.Type]
+				if !ok {
+					return fmt.Errorf("unsupported destination type: %s", dest.Type)
+				}
+
+				msg := &clients.Message{
+					CampaignID:  call.Campaign.ID,
+					CallID:      call.ID,
+					ThreadID:    call.ThreadID,
+					ScheduledAt: effectiveScheduledAt,
+					SourceURL:   call.SourceURL,
+					To:          to,
+					Author:      call.Author,
+					Subject:     subject,
+					Content:     content,
+					Priority:    dest.Priority,
+					Tags:        dest.Tags,
+					Click:       dest.Click,
+				}
+				if call.ThreadID != "" && call.ThreadID != call.ID {
+					root, err := w.store.FindSentMessage(call.Campaign.ID, call.ThreadID, dest.Type, to)
+					if err != nil && call.Campaign.LegacyID != "" {
+						root, err = w.store.FindSentMessage(call.Campaign.LegacyID, call.ThreadID, dest.Type, to)
+					}
+					if err == nil && root.Status == datastore.StatusSent {
+						msg.ReplyTo = root.Reference
+					}
+				}
+
+				slog.Info("sending message", "call_id", call.ID, "type", dest.Type, "destination", to, "scheduled_at", effectiveScheduledAt)
+				reference, err := sender.Send(msg)
+				sentMessage := &datastore.SentMessage{
+					SourceID:     call.ID,
+					ScheduledAt:  effectiveScheduledAt,
+					Reference:    reference,
+					Destination:  to,
+					Type:         dest.Type,
+					CampaignName: call.Campaign.Name,
+				}
+				// The rendered call is only kept for senders that need it, so
+				// that the datastore doesn't grow with the content of every call.
+				if _, ok := sender.(clients.Rebuilder); ok {
+					sentMessage.Author = call.Author
+					sentMessage.Subject = subject
+					sentMessage.Content = content
+				}
+
+				if err != nil {
+					sentMessage.Status = datastore.StatusFailed
+					slog.Error("failed to send message", "type", dest.Type, "error", err)
+				} else {
+					sentMessage.Status = datastore.StatusSent
+					slog.Info("sent message", "call_id", call.ID, "type", dest.Type, "destination", to, "scheduled_at", effectiveScheduledAt)
+				}
+
+				if err := w.store.AddSentMessage(call.Campaign.ID, call.ID, sentMessage); err != nil {
+					return err
+				}
 			}
 		}
 	}
 
 	return nil
 }
+
+// hasBeenSent reports whether a call has been sent to an address, under its
+// campaign's current or legacy ID.
+func (w *Worker) hasBeenSent(call *model.Call, destType, to string) (bool, error) {
+	sent, err := w.store.HasBeenSent(call.Campaign.ID, call.ID, destType, to)
+	if err != nil || sent || call.Campaign.LegacyID == "" {
+		return sent, err
+	}
+	return w.store.HasBeenSent(call.Campaign.LegacyID, call.ID, destType, to)
+}
+
+// addresses returns the addresses to send a call to for a destination. Feeds
+// without an address are published to a feed named after the campaign, so that
+// each campaign gets a feed of its own.
+func addresses(call *model.Call, dest model.Destination) []string {
+	if len(dest.To) == 0 && dest.Type == "feed" {
+		return []string{call.Campaign.ID}
+	}
+	return dest.To
+}
@@ -1,9 +1,12 @@
 package worker_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/andrewhowdencom/ruf/internal/clients"
 	"github.com/andrewhowdencom/ruf/internal/clients/email"
 	"github.com/andrewhowdencom/ruf/internal/clients/slack"
 	"github.com/andrewhowdencom/ruf/internal/datastore"
@@ -43,8 +46,8 @@ func TestWorker_RunTick(t *testing.T) {
 	// Mock Email client
 	emailClient := email.NewMockClient()
 	var capturedEmailAuthor string
-	emailClient.SendFunc = func(to []string, author, subject, body string) error {
-		capturedEmailAuthor = author
+	emailClient.SendFunc = func(msg *email.Message) error {
+		capturedEmailAuthor = msg.Author
 		return nil
 	}
 
@@ -221,6 +224,62 @@ func TestWorker_RunTickWithDeletedCall(t *testing.T) {
 	assert.Equal(t, 0, slackClient.PostMessageCount)
 }
 
+func TestWorker_RunTickWithLegacyCampaignID(t *testing.T) {
+	store := datastore.NewMockStore()
+	slackClient := slack.NewMockClient()
+	scheduledAt := time.Now().Add(-1 * time.Minute).UTC()
+
+	// The call was sent when its campaign ID was derived as "calls-yml".
+	err := store.AddSentMessage("calls-yml", "1:scheduled_at:"+scheduledAt.Format(time.RFC3339), &datastore.SentMessage{
+		SourceID:    "1",
+		ScheduledAt: scheduledAt,
+		Status:      datastore.StatusSent,
+		Type:        "slack",
+		Destination: "test-channel",
+	})
+	assert.NoError(t, err)
+
+	s := &mockSourcer{
+		sourcesBySource: map[string]*sourcer.Source{
+			"mock://url": {
+				Calls: []model.Call{
+					{
+						ID:      "1",
+						Subject: "Test Subject",
+						Content: "Hello, world!",
+						Destinations: []model.Destination{
+							{
+								Type: "slack",
+								To:   []string{"test-channel"},
+							},
+						},
+						Triggers: []model.Trigger{
+							{
+								ScheduledAt: scheduledAt,
+							},
+						},
+						Campaign: model.Campaign{
+							ID:       "calls",
+							Name:     "/calls.yml",
+							LegacyID: "calls-yml",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	p := poller.New(s, 1*time.Minute)
+	viper.Set("source.urls", []string{"mock://url"})
+	viper.Set("worker.lookback_period", "10m")
+
+	w := worker.New(store, slackClient, email.NewMockClient(), p, 1*time.Minute)
+	err = w.RunTick()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, slackClient.PostMessageCount)
+}
+
 func TestWorker_RunTickWithEvent(t *testing.T) {
 	// Mock datastore
 	store := datastore.NewMockStore()
@@ -287,3 +346,574 @@ func TestWorker_RunTickWithEvent(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, sentMessages, 2)
 }
+
+func TestWorker_RunTickThreadsEmailSequence(t *testing.T) {
+	// Mock datastore
+	store := datastore.NewMockStore()
+
+	// Mock Slack client
+	slackClient := slack.NewMockClient()
+
+	// Mock Email client
+	emailClient := email.NewMockClient()
+	sentBySubject := make(map[string]*email.Message)
+	emailClient.SendFunc = func(msg *email.Message) error {
+		sentBySubject[msg.Subject] = msg
+		return nil
+	}
+
+	emailDestinations := []model.Destination{
+		{
+			Type: "email",
+			To:   []string{"test@example.com"},
+		},
+	}
+
+	// Mock sourcer
+	s := &mockSourcer{
+		sourcesBySource: map[string]*sourcer.Source{
+			"mock://url": {
+				Calls: []model.Call{
+					{
+						ID:           "reminder",
+						Subject:      "Reminder",
+						Content:      "Starting soon!",
+						Destinations: emailDestinations,
+						Triggers: []model.Trigger{
+							{
+								Sequence: "test-sequence",
+								Delta:    "-5m",
+							},
+						},
+						Campaign: model.Campaign{
+							ID:   "mock-campaign",
+							Name: "Mock Campaign",
+						},
+					},
+					{
+						ID:           "announcement",
+						Subject:      "Announcement",
+						Content:      "Coming up!",
+						Destinations: emailDestinations,
+						Triggers: []model.Trigger{
+							{
+								Sequence: "test-sequence",
+								Delta:    "-30m",
+							},
+						},
+						Campaign: model.Campaign{
+							ID:   "mock-campaign",
+							Name: "Mock Campaign",
+						},
+					},
+				},
+				Events: []model.Event{
+					{
+						Sequence:  "test-sequence",
+						StartTime: time.Now(),
+					},
+				},
+			},
+		},
+	}
+
+	p := poller.New(s, 1*time.Minute)
+	viper.Set("source.urls", []string{"mock://url"})
+	viper.Set("worker.lookback_period", "1h")
+	viper.Set("email.from", "ruf@example.com")
+	defer viper.Set("email.from", "")
+
+	w := worker.New(store, slackClient, emailClient, p, 1*time.Minute)
+
+	err := w.RunTick()
+	assert.NoError(t, err)
+
+	announcement, reminder := sentBySubject["Announcement"], sentBySubject["Reminder"]
+	if assert.NotNil(t, announcement) && assert.NotNil(t, reminder) {
+		// The earliest call in the sequence starts the thread, and later calls reply to it.
+		assert.Contains(t, announcement.MessageID, "@example.com>")
+		assert.Empty(t, announcement.InReplyTo)
+		assert.Equal(t, announcement.MessageID, reminder.InReplyTo)
+		assert.NotEqual(t, announcement.MessageID, reminder.MessageID)
+	}
+
+	sentMessages, err := store.ListSentMessages()
+	assert.NoError(t, err)
+	assert.Len(t, sentMessages, 2)
+	for _, sm := range sentMessages {
+		assert.NotEmpty(t, sm.MessageID)
+		if strings.HasPrefix(sm.SourceID, "reminder:") {
+			assert.Equal(t, reminder.MessageID, sm.MessageID)
+			assert.Equal(t, announcement.MessageID, sm.InReplyTo)
+		}
+	}
+}
+
+func TestWorker_RunTickThreadsEmailWithLegacyCampaignID(t *testing.T) {
+	store := datastore.NewMockStore()
+	emailClient := email.NewMockClient()
+	sentBySubject := make(map[string]*email.Message)
+	emailClient.SendFunc = func(msg *email.Message) error {
+		sentBySubject[msg.Subject] = msg
+		return nil
+	}
+	startTime := time.Now().UTC().Truncate(time.Second)
+
+	// The thread was started when the campaign ID was derived as "calls-yml".
+	announcementID := "announcement:sequence:test-sequence:" + startTime.Format(time.RFC3339)
+	err := store.AddSentMessage("calls-yml", announcementID, &datastore.SentMessage{
+		SourceID:    announcementID,
+		ScheduledAt: startTime.Add(-30 * time.Minute),
+		MessageID:   "<ruf.calls-yml.announcement@example.com>",
+		Status:      datastore.StatusSent,
+		Type:        "email",
+		Destination: "test@example.com",
+	})
+	assert.NoError(t, err)
+
+	emailDestinations := []model.Destination{
+		{
+			Type: "email",
+			To:   []string{"test@example.com"},
+		},
+	}
+	campaign := model.Campaign{
+		ID:       "calls",
+		Name:     "/calls.yml",
+		LegacyID: "calls-yml",
+	}
+
+	s := &mockSourcer{
+		sourcesBySource: map[string]*sourcer.Source{
+			"mock://url": {
+				Calls: []model.Call{
+					{
+						ID:           "reminder",
+						Subject:      "Reminder",
+						Content:      "Starting soon!",
+						Destinations: emailDestinations,
+						Triggers:     []model.Trigger{{Sequence: "test-sequence", Delta: "-5m"}},
+						Campaign:     campaign,
+					},
+					{
+						ID:           "announcement",
+						Subject:      "Announcement",
+						Content:      "Coming up!",
+						Destinations: emailDestinations,
+						Triggers:     []model.Trigger{{Sequence: "test-sequence", Delta: "-30m"}},
+						Campaign:     campaign,
+					},
+				},
+				Events: []model.Event{
+					{
+						Sequence:  "test-sequence",
+						StartTime: startTime,
+					},
+				},
+			},
+		},
+	}
+
+	p := poller.New(s, 1*time.Minute)
+	viper.Set("source.urls", []string{"mock://url"})
+	viper.Set("worker.lookback_period", "1h")
+	viper.Set("email.from", "ruf@example.com")
+	defer viper.Set("email.from", "")
+
+	w := worker.New(store, slack.NewMockClient(), emailClient, p, 1*time.Minute)
+	err = w.RunTick()
+	assert.NoError(t, err)
+
+	assert.NotContains(t, sentBySubject, "Announcement")
+	if reminder := sentBySubject["Reminder"]; assert.NotNil(t, reminder) {
+		assert.Equal(t, "<ruf.calls-yml.announcement@example.com>", reminder.InReplyTo)
+	}
+}
+
+func TestWorker_RunTickCalendarInvites(t *testing.T) {
+	// Mock datastore
+	store := datastore.NewMockStore()
+
+	// Mock Slack client
+	slackClient := slack.NewMockClient()
+
+	// Mock Email client
+	emailClient := email.NewMockClient()
+	var sent []*email.Message
+	emailClient.SendFunc = func(msg *email.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+
+	viper.Set("source.urls", []string{"mock://url"})
+	viper.Set("worker.lookback_period", "1h")
+
+	source := &sourcer.Source{
+		URL:      "mock://url",
+		Campaign: model.Campaign{ID: "mock-campaign"},
+		Calls: []model.Call{
+			{
+				ID:      "reminder",
+				Subject: "Launch starts soon",
+				Content: "See you there!",
+				Destinations: []model.Destination{
+					{
+						Type: "email",
+						To:   []string{"test@example.com"},
+					},
+				},
+				Triggers: []model.Trigger{
+					{
+						Sequence: "launch",
+						Delta:    "-5m",
+					},
+				},
+				Campaign: model.Campaign{ID: "mock-campaign"},
+			},
+		},
+		Events: []model.Event{
+			{
+				Title:     "Product launch",
+				Sequence:  "launch",
+				StartTime: time.Now().Add(2 * time.Minute).UTC().Truncate(time.Second),
+				Duration:  "1h",
+				Invite:    true,
+			},
+		},
+	}
+
+	// Another file of the same campaign, without the event.
+	other := &sourcer.Source{URL: "mock://other", Campaign: model.Campaign{ID: "mock-campaign"}}
+
+	// Each tick uses a fresh poller so the unchanged mock state is seen again.
+	tick := func() {
+		s := &mockSourcer{sourcesBySource: map[string]*sourcer.Source{"mock://url": source, "mock://other": other}}
+		w := worker.New(store, slackClient, emailClient, poller.New(s, 1*time.Minute), 1*time.Minute)
+		assert.NoError(t, w.RunTick())
+	}
+	viper.Set("source.urls", []string{"mock://url", "mock://other"})
+
+	// The first call in the sequence carries the invite.
+	tick()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "REQUEST", sent[0].CalendarMethod)
+		assert.Contains(t, string(sent[0].Calendar), "SUMMARY:Product launch\r\n")
+		assert.Contains(t, string(sent[0].Calendar), "SEQUENCE:0\r\n")
+	}
+	invites, err := store.ListInvites()
+	assert.NoError(t, err)
+	if assert.Len(t, invites, 1) {
+		assert.Equal(t, "test@example.com", invites[0].Recipient)
+		assert.Equal(t, source.Events[0].StartTime.Add(time.Hour), invites[0].EndTime)
+		assert.Equal(t, "mock://url", invites[0].SourceURL)
+	}
+	uid := invites[0].UID
+
+	// Moving the event sends an update with the same UID straight away.
+	source.Events[0].StartTime = source.Events[0].StartTime.Add(time.Hour)
+	tick()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "Updated: Product launch", sent[1].Subject)
+		assert.Equal(t, fmt.Sprintf("Product launch now runs from %s to %s.",
+			source.Events[0].StartTime.Format(time.RFC1123),
+			source.Events[0].StartTime.Add(time.Hour).Format(time.RFC1123)), sent[1].Body)
+		assert.Equal(t, "REQUEST", sent[1].CalendarMethod)
+		assert.Contains(t, string(sent[1].Calendar), "UID:"+uid+"\r\n")
+		assert.Contains(t, string(sent[1].Calendar), "SEQUENCE:1\r\n")
+		assert.Contains(t, string(sent[1].Calendar), "DTSTART:"+source.Events[0].StartTime.Format("20060102T150405Z")+"\r\n")
+	}
+
+	// Changing only the end says so.
+	source.Events[0].Duration = "2h"
+	tick()
+	if assert.Len(t, sent, 3) {
+		assert.Equal(t, fmt.Sprintf("Product launch now ends at %s.",
+			source.Events[0].StartTime.Add(2*time.Hour).Format(time.RFC1123)), sent[2].Body)
+		assert.Contains(t, string(sent[2].Calendar), "SEQUENCE:2\r\n")
+	}
+	sent = sent[:2]
+
+	// Removing the event cancels it.
+	source.Events = nil
+	tick()
+	if assert.Len(t, sent, 3) {
+		assert.Equal(t, "Cancelled: Product launch", sent[2].Subject)
+		assert.Equal(t, "CANCEL", sent[2].CalendarMethod)
+		assert.Contains(t, string(sent[2].Calendar), "UID:"+uid+"\r\n")
+		assert.Contains(t, string(sent[2].Calendar), "SEQUENCE:3\r\n")
+	}
+	inv, err := store.GetInvite(uid, "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, datastore.StatusDeleted, inv.Status)
+
+	// Nothing more is sent once the invite has been cancelled.
+	tick()
+	assert.Len(t, sent, 3)
+}
+
+func TestWorker_RunTickLeavesPastInvites(t *testing.T) {
+	store := datastore.NewMockStore()
+	emailClient := email.NewMockClient()
+	var sent []*email.Message
+	emailClient.SendFunc = func(msg *email.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+
+	// The event ended an hour ago, and has since been removed from its file.
+	start := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	assert.NoError(t, store.AddInvite(&datastore.Invite{
+		UID:        "past@ruf",
+		CampaignID: "mock-campaign",
+		SourceURL:  "mock://url",
+		Recipient:  "test@example.com",
+		Summary:    "Product launch",
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+		Status:     datastore.StatusSent,
+	}))
+
+	s := &mockSourcer{sourcesBySource: map[string]*sourcer.Source{
+		"mock://url": {URL: "mock://url", Campaign: model.Campaign{ID: "mock-campaign"}},
+	}}
+	viper.Set("source.urls", []string{"mock://url"})
+	w := worker.New(store, slack.NewMockClient(), emailClient, poller.New(s, 1*time.Minute), 1*time.Minute)
+	assert.NoError(t, w.RunTick())
+
+	assert.Empty(t, sent)
+	inv, err := store.GetInvite("past@ruf", "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, datastore.StatusSent, inv.Status)
+}
+
+// mockSender implements the clients.Sender interface for testing.
+type mockSender struct {
+	messages  []*clients.Message
+	reference string
+	err       error
+}
+
+func (m *mockSender) Send(msg *clients.Message) (string, error) {
+	m.messages = append(m.messages, msg)
+	return m.reference, m.err
+}
+
+func TestWorker_RunTickWithSender(t *testing.T) {
+	// Mock datastore
+	store := datastore.NewMockStore()
+
+	// Mock Slack client
+	slackClient := slack.NewMockClient()
+
+	// Mock Email client
+	emailClient := email.NewMockClient()
+
+	// Mock sourcer
+	s := &mockSourcer{
+		sourcesBySource: map[string]*sourcer.Source{
+			"mock://url": {
+				Calls: []model.Call{
+					{
+						ID:      "1",
+						Author:  "test@author.com",
+						Subject: "Test Subject",
+						Content: "Hello, {{ \"world\" }}!",
+						Destinations: []model.Destination{
+							{
+								Type: "mock",
+								To:   []string{"engineering"},
+							},
+							{
+								Type: "unknown",
+								To:   []string{"somewhere"},
+							},
+						},
+						Triggers: []model.Trigger{
+							{
+								ScheduledAt: time.Now().Add(-1 * time.Minute),
+							},
+						},
+						Campaign: model.Campaign{
+							ID:   "mock-campaign",
+							Name: "Mock Campaign",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	p := poller.New(s, 1*time.Minute)
+	viper.Set("source.urls", []string{"mock://url"})
+	viper.Set("worker.lookback_period", "10m")
+
+	sender := &mockSender{reference: "message-1"}
+	w := worker.New(store, slackClient, emailClient, p, 1*time.Minute)
+	w.AddSender("mock", sender)
+
+	err := w.RunTick()
+	assert.NoError(t, err)
+
+	if assert.Len(t, sender.messages, 1) {
+		msg := sender.messages[0]
+		assert.Equal(t, "engineering", msg.To)
+		assert.Equal(t, "test@author.com", msg.Author)
+		assert.Equal(t, "Test Subject", msg.Subject)
+		assert.Equal(t, "Hello, world!", msg.Content)
+		assert.Equal(t, "mock-campaign", msg.CampaignID)
+	}
+
+	// Destinations without a sender are not recorded.
+	sentMessages, err := store.ListSentMessages()
+	assert.NoError(t, err)
+	if assert.Len(t, sentMessages, 1) {
+		assert.Equal(t, datastore.StatusSent, sentMessages[0].Status)
+		assert.Equal(t, "mock", sentMessages[0].Type)
+		assert.Equal(t, "message-1", sentMessages[0].Reference)
+		// The rendered call is only kept for senders that are rebuilt from
+		// their records.
+		assert.Empty(t, sentMessages[0].Content)
+	}
+}
+
+// mockRebuilder is a mockSender that is rebuilt from its records, like feeds.
+type mockRebuilder struct {
+	mockSender
+}
+
+func (m *mockRebuilder) RebuildsFromRecords() {}
+
+func TestWorker_RunTickWithFeed(t *testing.T) {
+	store := datastore.NewMockStore()
+	s := &mockSourcer{
+		sourcesBySource: map[string]*sourcer.Source{
+			"mock://url": {
+				Calls: []model.Call{
+					{
+						ID:      "1",
+						Author:  "test@author.com",
+						Subject: "Test Subject",
+						Content: "Hello, {{ \"world\" }}!",
+						Destinations: []model.Destination{
+							{Type: "feed"},
+							{Type: "feed", To: []string{"all"}},
+						},
+						Triggers: []model.Trigger{
+							{
+								ScheduledAt: time.Now().Add(-1 * time.Minute),
+							},
+						},
+						Campaign: model.Campaign{
+							ID:   "mock-campaign",
+							Name: "Mock Campaign",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	p := poller.New(s, 1*time.Minute)
+	viper.Set("source.urls", []string{"mock://url"})
+	viper.Set("worker.lookback_period", "10m")
+
+	sender := &mockRebuilder{mockSender{reference: "entry-1"}}
+	w := worker.New(store, slack.NewMockClient(), email.NewMockClient(), p, 1*time.Minute)
+	w.AddSender("feed", sender)
+
+	err := w.RunTick()
+	assert.NoError(t, err)
+
+	// Feeds without an address are named after the campaign.
+	if assert.Len(t, sender.messages, 2) {
+		assert.Equal(t, "mock-campaign", sender.messages[0].To)
+		assert.Equal(t, "all", sender.messages[1].To)
+	}
+
+	sentMessages, err := store.ListSentMessages()
+	assert.NoError(t, err)
+	if assert.Len(t, sentMessages, 2) {
+		for _, sm := range sentMessages {
+			assert.Equal(t, "test@author.com", sm.Author)
+			assert.Equal(t, "Test Subject", sm.Subject)
+			assert.Equal(t, "Hello, world!", sm.Content)
+		}
+	}
+}
+
+func TestWorker_RunTickRepliesInThread(t *testing.T) {
+	// Mock datastore
+	store := datastore.NewMockStore()
+
+	// Mock Slack client
+	slackClient := slack.NewMockClient()
+
+	// Mock Email client
+	emailClient := email.NewMockClient()
+
+	destinations := []model.Destination{
+		{
+			Type: "mock",
+			To:   []string{"~general"},
+		},
+	}
+
+	// Mock sourcer. The reply is listed first, but is scheduled later.
+	s := &mockSourcer{
+		sourcesBySource: map[string]*sourcer.Source{
+			"mock://url": {
+				Calls: []model.Call{
+					{
+						ID:           "reply",
+						Content:      "Starting soon!",
+						Destinations: destinations,
+						Triggers: []model.Trigger{
+							{
+								Sequence: "test-sequence",
+								Delta:    "-5m",
+							},
+						},
+						Campaign: model.Campaign{ID: "mock-campaign"},
+					},
+					{
+						ID:           "root",
+						Content:      "Coming up!",
+						Destinations: destinations,
+						Triggers: []model.Trigger{
+							{
+								Sequence: "test-sequence",
+								Delta:    "-30m",
+							},
+						},
+						Campaign: model.Campaign{ID: "mock-campaign"},
+					},
+				},
+				Events: []model.Event{
+					{
+						Sequence:  "test-sequence",
+						StartTime: time.Now(),
+					},
+				},
+			},
+		},
+	}
+
+	p := poller.New(s, 1*time.Minute)
+	viper.Set("source.urls", []string{"mock://url"})
+	viper.Set("worker.lookback_period", "1h")
+
+	sender := &mockSender{reference: "post-1"}
+	w := worker.New(store, slackClient, emailClient, p, 1*time.Minute)
+	w.AddSender("mock", sender)
+
+	err := w.RunTick()
+	assert.NoError(t, err)
+
+	if assert.Len(t, sender.messages, 2) {
+		assert.Equal(t, "Coming up!", sender.messages[0].Content)
+		assert.Empty(t, sender.messages[0].ReplyTo)
+		assert.Equal(t, "Starting soon!", sender.messages[1].Content)
+		assert.Equal(t, "post-1", sender.messages[1].ReplyTo)
+		assert.Equal(t, sender.messages[0].CallID, sender.messages[1].ThreadID)
+	}
+}